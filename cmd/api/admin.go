@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/events"
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type UpdateUserRolePayload struct {
+	Role string `json:"role" validate:"required,max=255"`
+}
+
+type SuspendUserPayload struct {
+	Reason string    `json:"reason" validate:"required,max=255"`
+	Until  time.Time `json:"until" validate:"required"`
+}
+
+type BanUserPayload struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+func (app *application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.store.Roles.GetAll(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := jsonResponse(w, http.StatusOK, roles); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) updateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.moderatedUserID(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	var payload UpdateUserRolePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	role, err := app.store.Roles.GetByName(ctx, payload.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	target, err := app.store.Users.GetUserByID(ctx, userID)
+	if err != nil {
+		app.moderationError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.UpdateRole(ctx, userID, role.ID); err != nil {
+		app.moderationError(w, r, err)
+		return
+	}
+
+	app.audit(r, getUserFromContext(r).ID, auditUserRoleUpdate, auditTargetUser, userID,
+		map[string]string{"role": target.Role.Name},
+		map[string]string{"role": role.Name},
+	)
+
+	if err := jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.moderatedUserID(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	var payload SuspendUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if !payload.Until.After(time.Now()) {
+		app.badRequest(w, r, clientError("suspension end must be in the future"))
+		return
+	}
+
+	app.suspendUser(w, r, userID, auditUserSuspend, payload.Reason, &payload.Until)
+}
+
+func (app *application) banUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.moderatedUserID(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	var payload BanUserPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	app.suspendUser(w, r, userID, auditUserBan, payload.Reason, nil)
+}
+
+func (app *application) unbanUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.moderatedUserID(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	target, err := app.store.Users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		app.moderationError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.Unsuspend(r.Context(), userID); err != nil {
+		app.moderationError(w, r, err)
+		return
+	}
+
+	app.audit(r, getUserFromContext(r).ID, auditUserUnban, auditTargetUser, userID, target.Suspension, nil)
+
+	if err := jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) suspendUser(w http.ResponseWriter, r *http.Request, userID int64, action, reason string, until *time.Time) {
+	target, err := app.store.Users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		app.moderationError(w, r, err)
+		return
+	}
+
+	if err := app.store.Users.Suspend(r.Context(), userID, reason, until); err != nil {
+		app.moderationError(w, r, err)
+		return
+	}
+
+	suspension := &store.Suspension{
+		Reason:    reason,
+		CreatedAt: time.Now(),
+		Until:     until,
+	}
+	app.audit(r, getUserFromContext(r).ID, action, auditTargetUser, userID, target.Suspension, suspension)
+
+	// the open streams of the user are closed, new ones are refused.
+	event, err := newEvent(events.UserSuspended, getUserFromContext(r).ID, map[string]int64{"user_id": userID}, 0, userID)
+	if err != nil {
+		app.requestLogger(r).Error("error encoding event", "type", events.UserSuspended, "error", err.Error())
+	} else {
+		app.broadcast(r, event)
+	}
+
+	if err := jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// moderatedUserID returns the target user of an admin request, admins are
+// not allowed to moderate their own account.
+func (app *application) moderatedUserID(r *http.Request) (int64, error) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if userID == getUserFromContext(r).ID {
+		return 0, clientError("admins can not moderate their own account")
+	}
+
+	return userID, nil
+}
+
+func (app *application) moderationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		app.notFoundError(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
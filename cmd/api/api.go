@@ -3,18 +3,22 @@ package main
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/MohummedSoliman/social/internal/auth"
+	"github.com/MohummedSoliman/social/internal/events"
 	"github.com/MohummedSoliman/social/internal/mailer"
+	"github.com/MohummedSoliman/social/internal/metrics"
 	"github.com/MohummedSoliman/social/internal/ratelimiter"
 	"github.com/MohummedSoliman/social/internal/store"
 	"github.com/MohummedSoliman/social/internal/store/cache"
+	"github.com/MohummedSoliman/social/internal/webhook"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -23,11 +27,18 @@ import (
 type application struct {
 	config        config
 	store         store.Storage
+	replicas      *store.Replicas
 	db            dbConfig
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	cacheStore    cache.Storage
 	ratelimiter   ratelimiter.Limiter
+	events        events.Broker
+	webhooks      *webhook.Client
+	permissions   permissionCache
+	logger        *slog.Logger
+	healthChecks  []healthCheck
+	shuttingDown  atomic.Bool
 }
 
 type config struct {
@@ -36,7 +47,36 @@ type config struct {
 	frontendURL string
 	auth        authConfig
 	redisConfig redisConfig
+	cache       cacheConfig
 	rateLimiter ratelimiter.Config
+	account     accountConfig
+	webhooks    webhookConfig
+	tracing     tracingConfig
+	shutdown    shutdownConfig
+	// docsDir holds the Swagger UI assets served with /v1/docs, make
+	// swagger-ui installs them.
+	docsDir string
+}
+
+// shutdownConfig keeps the server up for drainPeriod after it reports not
+// ready, so the readiness probes see it before the listener closes. It
+// should be longer than the probe period.
+type shutdownConfig struct {
+	drainPeriod time.Duration
+	timeout     time.Duration
+}
+
+type accountConfig struct {
+	deletionGracePeriod time.Duration
+	purgeInterval       time.Duration
+}
+
+type webhookConfig struct {
+	pollInterval time.Duration
+	batchSize    int
+	lease        time.Duration
+	maxAttempts  int
+	timeout      time.Duration
 }
 
 type redisConfig struct {
@@ -46,6 +86,14 @@ type redisConfig struct {
 	enabled  bool
 }
 
+// cacheConfig enables the in-process cache in front of Redis. Without Redis
+// it is the only cache.
+type cacheConfig struct {
+	localEnabled bool
+	localSize    int
+	localTTL     time.Duration
+}
+
 type authConfig struct {
 	basic basicConfig
 	token tokenConfig
@@ -62,8 +110,9 @@ type basicConfig struct {
 }
 
 type mailConfig struct {
-	expiry   time.Duration
-	sendGrid sendGridConfig
+	expiry            time.Duration
+	emailChangeExpiry time.Duration
+	sendGrid          sendGridConfig
 }
 
 type sendGridConfig struct {
@@ -71,12 +120,24 @@ type sendGridConfig struct {
 	fromEmail string
 }
 
+type tracingConfig struct {
+	exporter    string
+	endpoint    string
+	insecure    bool
+	sampleRatio float64
+}
+
 type dbConfig struct {
 	addr         string
 	maxOpenConns int
-	maxIdleConns int
+	minConns     int
 	maxIdleTime  string
 	env          string
+	migrate      bool
+	replicaAddrs []string
+	// readYourWritesWindow is how long a client reads from the primary
+	// after writing, it should cover the replication lag.
+	readYourWritesWindow time.Duration
 }
 
 func (app *application) mount() http.Handler {
@@ -84,26 +145,45 @@ func (app *application) mount() http.Handler {
 
 	mux.Use(middleware.RealIP)
 	mux.Use(middleware.RequestID)
+	mux.Use(app.TracingMiddleware)
+	mux.Use(app.RequestLoggerMiddleware)
+	mux.Use(app.MetricsMiddleware)
 	mux.Use(middleware.Recoverer)
-	mux.Use(middleware.Logger)
 	mux.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://*", "https://*"},
 		AllowedMethods:   []string{"GET", "POST", "POST", "PUT", "OPTIONS", "DELETE"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag", "Idempotent-Replayed"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 	mux.Use(app.RateLimiterMiddleware)
+	mux.Use(app.ReadYourWritesMiddleware)
+
+	mux.Handle("/metrics", metrics.Handler())
+
+	// The event stream is long lived, so it is kept out of the request timeout.
+	mux.With(app.StreamTokenMiddleware, app.AuthTokenMiddleware()).Get("/v1/stream", app.streamHandler)
 
-	mux.Use(middleware.Timeout(60 * time.Second))
+	mux.With(middleware.Timeout(60*time.Second)).Route("/v1", func(r chi.Router) {
+		r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
+		r.Get("/health/live", app.livenessHandler)
+		r.Get("/health/ready", app.readinessHandler)
 
-	mux.Route("/v1", func(r chi.Router) {
-		// r.With(app.BasicAuthMiddleware()).Get("/health", app.healthCheckHandler)
-		r.Get("/health", app.healthCheckHandler)
+		r.Get("/openapi.json", app.openAPIHandler)
+		r.Get("/docs", app.docsHandler)
+		r.Handle("/docs/assets/*", http.StripPrefix("/v1/docs/assets/", http.FileServer(http.Dir(app.config.docsDir))))
 
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/email/confirm/{token}", app.confirmEmailChangeHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware())
+
+				r.Delete("/", app.deleteAccountHandler)
+				r.Get("/export", app.exportUserDataHandler)
+			})
 
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware())
@@ -116,23 +196,60 @@ func (app *application) mount() http.Handler {
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware())
 				r.Get("/feed", app.getUserFeedhandler)
+				r.Post("/email", app.requestEmailChangeHandler)
 			})
 		})
 
 		r.Route("/authentication", func(r chi.Router) {
-			r.Post("/user", app.registerUserHandler)
+			r.With(app.IdempotencyMiddleware).Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
 		})
 
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware())
+
+			r.Get("/", app.listWebhooksHandler)
+			r.Post("/", app.createWebhookHandler)
+
+			r.Route("/{webhookID}", func(r chi.Router) {
+				r.Delete("/", app.deleteWebhookHandler)
+				r.Get("/deliveries", app.listWebhookDeliveriesHandler)
+				r.Post("/deliveries/{deliveryID}/redeliver", app.redeliverWebhookHandler)
+			})
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware())
+
+			r.With(app.RequirePermission(permManageRoles)).Get("/roles", app.listRolesHandler)
+			r.With(app.RequirePermission(permReadAuditLog)).Get("/audit", app.listAuditLogHandler)
+
+			r.Route("/users/{userID}", func(r chi.Router) {
+				r.With(app.RequirePermission(permManageRoles)).Put("/role", app.updateUserRoleHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.RequirePermission(permBanUsers))
+
+					r.Put("/suspend", app.suspendUserHandler)
+					r.Put("/ban", app.banUserHandler)
+					r.Put("/unban", app.unbanUserHandler)
+				})
+			})
+		})
+
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware())
 
-			r.Post("/", app.createPostHandler)
+			r.With(app.IdempotencyMiddleware).Post("/", app.createPostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
+				r.Use(app.postContextMiddleware)
+
 				r.Get("/", app.getPostHandler)
-				r.Delete("/", app.checkPostOwnership("admin", app.deletePostHandler))
-				r.Patch("/", app.checkPostOwnership("moderator", app.updatePostHandler))
+				r.Delete("/", app.checkPostOwnership(permDeleteAnyPost, app.deletePostHandler))
+				r.Patch("/", app.checkPostOwnership(permUpdateAnyPost, app.updatePostHandler))
+
+				r.Post("/comments", app.createCommentHandler)
 			})
 		})
 	})
@@ -151,20 +268,50 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan error)
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	if app.replicas != nil {
+		go app.replicas.Run(jobsCtx, time.Second*5, app.logger)
+	}
+
+	go app.purgeDeletedUsers(jobsCtx)
+	go app.deliverWebhooks(jobsCtx)
+
+	go func() {
+		if err := app.cacheStore.Run(jobsCtx); err != nil {
+			app.logger.Error("cache invalidation stopped", "error", err.Error())
+		}
+	}()
+
+	go func() {
+		if err := app.events.Run(jobsCtx); err != nil {
+			app.logger.Error("event broker stopped", "error", err.Error())
+		}
+	}()
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		s := <-quit
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		app.logger.Info("signal caught", "signal", s.String())
+
+		// report not ready first, so load balancers stop routing new requests.
+		app.shuttingDown.Store(true)
+
+		app.logger.Info("draining", "period", app.config.shutdown.drainPeriod.String())
+		time.Sleep(app.config.shutdown.drainPeriod)
 
-		log.Println("Signal Caught: ", s.String())
+		stopJobs()
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.config.shutdown.timeout)
+		defer cancel()
 
 		shutdown <- srv.Shutdown(ctx)
 	}()
 
-	log.Println("Server has started at: ", app.config.addr)
+	app.logger.Info("server has started", "addr", app.config.addr, "env", app.db.env)
 
 	err := srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
@@ -176,6 +323,6 @@ func (app *application) run(mux http.Handler) error {
 		return err
 	}
 
-	log.Println("Server has stopped")
+	app.logger.Info("server has stopped")
 	return nil
 }
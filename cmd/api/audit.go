@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	auditUserLogin      = "user.login"
+	auditUserRoleUpdate = "user.role.update"
+	auditUserSuspend    = "user.suspend"
+	auditUserBan        = "user.ban"
+	auditUserUnban      = "user.unban"
+	auditPostUpdate     = "post.update"
+	auditPostDelete     = "post.delete"
+
+	auditTargetUser = "user"
+	auditTargetPost = "post"
+)
+
+// audit records a privileged action, a failing write is logged but never
+// fails the request that already happened.
+func (app *application) audit(r *http.Request, actorID int64, action, targetType string, targetID int64, before, after any) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	entry := &store.AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         ip,
+		RequestID:  middleware.GetReqID(r.Context()),
+	}
+
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			app.requestLogger(r).Error("error encoding audit entry", "action", action, "error", err.Error())
+			return
+		}
+	}
+
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			app.requestLogger(r).Error("error encoding audit entry", "action", action, "error", err.Error())
+			return
+		}
+	}
+
+	if err := app.store.AuditLogs.Create(r.Context(), entry); err != nil {
+		app.requestLogger(r).Error("error writing audit entry", "action", action, "error", err.Error())
+	}
+}
+
+func (app *application) listAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	q := store.AuditLogQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	q, err := q.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	entries, err := app.store.AuditLogs.List(r.Context(), q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := jsonResponse(w, http.StatusOK, entries); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
@@ -89,7 +88,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	if err != nil {
 		// rollback user creation if email fails (SAGA pattern).
 		if err := app.store.Users.Delete(r.Context(), user.ID); err != nil {
-			log.Printf("error deleting user %d", user.ID)
+			app.requestLogger(r).Error("error deleting user", "user_id", user.ID, "error", err.Error())
 		}
 		app.internalServerError(w, r, err)
 		return
@@ -104,7 +103,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
 
-	err := app.store.Users.ActivateUser(r.Context(), token)
+	_, err := app.store.Users.ActivateUser(r.Context(), token)
 	if err != nil {
 		switch err {
 		case store.ErrNotFound:
@@ -157,6 +156,11 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if user.IsSuspended() {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
 	claims := jwt.MapClaims{
 		"sub": user.ID,
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
@@ -171,6 +175,8 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	app.audit(r, user.ID, auditUserLogin, auditTargetUser, user.ID, nil, nil)
+
 	if err := jsonResponse(w, http.StatusCreated, token); err != nil {
 		app.internalServerError(w, r, err)
 	}
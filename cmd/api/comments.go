@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/MohummedSoliman/social/internal/events"
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+type CreateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=1000"`
+}
+
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromContext(r)
+
+	var payload CreateCommentPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	comment := &store.Comment{
+		UserID:  user.ID,
+		PostID:  post.ID,
+		Content: payload.Content,
+		User:    store.User{ID: user.ID, Username: user.Username},
+	}
+
+	var event events.Event
+	err := app.store.Tx.InTx(r.Context(), nil, func(ctx context.Context) error {
+		if err := app.store.Comments.Create(ctx, comment); err != nil {
+			return err
+		}
+
+		var err error
+		if event, err = newEvent(events.CommentCreated, user.ID, comment, post.UserID, post.UserID); err != nil {
+			return err
+		}
+		return app.enqueueWebhooks(ctx, event)
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.broadcast(r, event)
+
+	if err := jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -1,43 +1,189 @@
 package main
 
 import (
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
 )
 
+// clientError is a bad request reason written for clients, badRequest
+// sends it as the detail.
+type clientError string
+
+func (e clientError) Error() string {
+	return string(e)
+}
+
+// clientSafeErrors are store errors whose text is fixed and safe to send.
+var clientSafeErrors = []error{store.ErrNotFound, store.ErrDuplicateEmail, store.ErrDuplicateUsername}
+
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	log.Printf("internal server error : %s path: %s error: %s", r.Method, r.URL.Path, err)
-	writeJSONError(w, http.StatusInternalServerError, "Server Encounter a Problem!!")
+	app.requestLogger(r).Error("internal server error", "error", err.Error())
+	app.problemResponse(w, r, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
 }
 
 func (app *application) badRequest(w http.ResponseWriter, r *http.Request, err error) {
-	log.Printf("bad request error : %s path: %s error: %s", r.Method, r.URL.Path, err)
-	writeJSONError(w, http.StatusBadRequest, "Bad Request Data")
+	app.requestLogger(r).Warn("bad request error", "error", err.Error())
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		p := newProblem(r, http.StatusBadRequest, "one or more fields are invalid")
+		p.Errors = toFieldErrors(validationErrs)
+		writeProblem(w, p)
+		return
+	}
+
+	app.problemResponse(w, r, http.StatusBadRequest, badRequestDetail(err))
+}
+
+// badRequestDetail returns a fixed detail for the class of err, the text
+// of decoder and parser errors is only logged.
+func badRequestDetail(err error) string {
+	var (
+		ce          clientError
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		maxBytesErr *http.MaxBytesError
+		numErr      *strconv.NumError
+		timeErr     *time.ParseError
+	)
+
+	for _, safe := range clientSafeErrors {
+		if errors.Is(err, safe) {
+			return safe.Error()
+		}
+	}
+
+	switch {
+	case errors.As(err, &ce):
+		return string(ce)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return "the body contains badly-formed JSON"
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("the body contains an invalid value for the %q field", typeErr.Field)
+	case errors.As(err, &typeErr):
+		return "the body contains a value of the wrong type"
+	case errors.Is(err, io.EOF):
+		return "the body must not be empty"
+	case strings.HasPrefix(err.Error(), "json: unknown field"):
+		return "the body contains an unknown field"
+	case errors.As(err, &maxBytesErr):
+		return fmt.Sprintf("the body must not be larger than %d bytes", maxBytesErr.Limit)
+	case errors.As(err, &numErr):
+		return "a number in the request is invalid"
+	case errors.As(err, &timeErr):
+		return "a time in the request is invalid"
+	default:
+		return "the request is invalid"
+	}
 }
 
 func (app *application) notFoundError(w http.ResponseWriter, r *http.Request, err error) {
-	log.Printf("not found error : %s path: %s error: %s", r.Method, r.URL.Path, err)
-	writeJSONError(w, http.StatusNotFound, "Bad Request Data")
+	app.requestLogger(r).Warn("not found error", "error", err.Error())
+	app.problemResponse(w, r, http.StatusNotFound, "the requested resource could not be found")
 }
 
 func (app *application) unauthorizedError(w http.ResponseWriter, r *http.Request, err error) {
-	log.Printf("UnAuthorized Error : %s path: %s error: %s", r.Method, r.URL.Path, err)
-	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	app.requestLogger(r).Warn("unauthorized error", "error", err.Error())
+	app.problemResponse(w, r, http.StatusUnauthorized, "invalid or missing authentication credentials")
 }
 
 func (app *application) unauthorizedBasicError(w http.ResponseWriter, r *http.Request, err error) {
-	log.Printf("UnAuthorized Basic Error :  %s path: %s error: %s", r.Method, r.URL.Path, err)
+	app.requestLogger(r).Warn("unauthorized basic error", "error", err.Error())
 	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	app.problemResponse(w, r, http.StatusUnauthorized, "invalid or missing authentication credentials")
 }
 
 func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Forbidden Error :  %s path: %s ", r.Method, r.URL.Path)
-	writeJSONError(w, http.StatusForbidden, "Forbidden")
+	app.requestLogger(r).Warn("forbidden error")
+	app.problemResponse(w, r, http.StatusForbidden, "you are not allowed to perform this action")
 }
 
 func (app *application) rateLimiterExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
-	log.Printf("Rate Limiter Exceeded for path %s", r.URL.Path)
+	app.requestLogger(r).Warn("rate limiter exceeded", "retry_after", retryAfter)
 	w.Header().Set("Retry-After", retryAfter)
-	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
+	app.problemResponse(w, r, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	app.requestLogger(r).Warn("precondition failed")
+	app.problemResponse(w, r, http.StatusPreconditionFailed, "the resource was modified, fetch it again and retry")
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.requestLogger(r).Warn("precondition required")
+	app.problemResponse(w, r, http.StatusPreconditionRequired, "this request requires an If-Match header")
+}
+
+func (app *application) idempotencyKeyReusedResponse(w http.ResponseWriter, r *http.Request) {
+	app.requestLogger(r).Warn("idempotency key reused with a different request")
+	app.problemResponse(w, r, http.StatusUnprocessableEntity, "the Idempotency-Key was already used for a different request")
+}
+
+func (app *application) idempotencyInProgressResponse(w http.ResponseWriter, r *http.Request) {
+	app.requestLogger(r).Warn("idempotent request still in progress")
+	app.problemResponse(w, r, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+}
+
+func (app *application) problemResponse(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	if err := writeProblem(w, newProblem(r, status, detail)); err != nil {
+		app.requestLogger(r).Error("error writing problem response", "error", err.Error())
+	}
+}
+
+func newProblem(r *http.Request, status int, detail string) Problem {
+	return Problem{
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+func toFieldErrors(errs validator.ValidationErrors) []FieldError {
+	fieldErrs := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fieldErrs = append(fieldErrs, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: validationMessage(fe),
+		})
+	}
+	return fieldErrs
+}
+
+func validationMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind().String() {
+	case "string":
+		unit = " characters"
+	case "slice", "array", "map":
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit)
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		return fmt.Sprintf("failed on the %q rule", fe.Tag())
+	}
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBadRequestProblem(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+
+	t.Run("Should report invalid fields as problem details", func(t *testing.T) {
+		body := strings.NewReader(`{"username": "gopher", "email": "not-an-email", "password": ""}`)
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/user", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reqRec := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, reqRec.Code)
+
+		if ct := reqRec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("Expected Content-Type application/problem+json, but got %s", ct)
+		}
+
+		var p Problem
+		if err := json.NewDecoder(reqRec.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		fields := make(map[string]string)
+		for _, fe := range p.Errors {
+			fields[fe.Field] = fe.Rule
+		}
+
+		if fields["email"] != "email" || fields["password"] != "required" || len(fields) != 2 {
+			t.Errorf("Expected errors for email and password, but got %+v", p.Errors)
+		}
+	})
+	t.Run("Should not send decoder errors to clients", func(t *testing.T) {
+		tests := []struct {
+			body   string
+			detail string
+		}{
+			{`{"username": "gopher",`, "the body contains badly-formed JSON"},
+			{`{"username": 42}`, `the body contains an invalid value for the "username" field`},
+			{`{"unknown": true}`, "the body contains an unknown field"},
+			{``, "the body must not be empty"},
+		}
+
+		for _, tt := range tests {
+			req, err := http.NewRequest(http.MethodPost, "/v1/authentication/user", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reqRec := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusBadRequest, reqRec.Code)
+
+			var p Problem
+			if err := json.NewDecoder(reqRec.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Detail != tt.detail {
+				t.Errorf("Expected detail %q for body %q, but got %q", tt.detail, tt.body, p.Detail)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func (app *application) exportUserDataHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+
+	posts, err := app.store.Posts.GetByUserID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	comments, err := app.store.Comments.GetByUserID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	followers, err := app.store.Followers.GetFollowers(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	following, err := app.store.Followers.GetFollowing(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", user},
+		{"posts.json", posts},
+		{"comments.json", comments},
+		{"followers.json", followers},
+		{"following.json", following},
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="gophersocial-export-%d.zip"`, user.ID))
+	w.WriteHeader(http.StatusOK)
+
+	// headers are already sent at this point, so failures can only be logged.
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			app.requestLogger(r).Error("error exporting user data", "error", err.Error())
+			return
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			app.requestLogger(r).Error("error exporting user data", "error", err.Error())
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		app.requestLogger(r).Error("error exporting user data", "error", err.Error())
+	}
+}
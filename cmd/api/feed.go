@@ -21,7 +21,7 @@ func (app *application) getUserFeedhandler(w http.ResponseWriter, r *http.Reques
 
 	err = Validate.Struct(fq)
 	if err != nil {
-		app.internalServerError(w, r, err)
+		app.badRequest(w, r, err)
 		return
 	}
 
@@ -1,17 +1,119 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"sync"
+	"time"
 )
 
-func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+const healthCheckTimeout = time.Second * 2
+
+// healthCheck pings a dependency the API needs to serve requests.
+type healthCheck struct {
+	name  string
+	check func(context.Context) error
+}
+
+type dependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
 	data := map[string]string{
-		"status":  "OK",
-		"env":     app.db.env,
-		"version": version,
+		"status": "OK",
 	}
 
 	if err := writeJSON(w, http.StatusOK, data); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	ready, _ := app.checkReadiness(r.Context())
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not ready", http.StatusServiceUnavailable
+	}
+
+	data := map[string]string{
+		"status": status,
+	}
+
+	if err := writeJSON(w, code, data); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	ready, dependencies := app.checkReadiness(r.Context())
+
+	status, code := "OK", http.StatusOK
+	if !ready {
+		status, code = "Unavailable", http.StatusServiceUnavailable
+	}
+
+	data := struct {
+		Status       string                      `json:"status"`
+		Env          string                      `json:"env"`
+		Version      string                      `json:"version"`
+		ShuttingDown bool                        `json:"shutting_down"`
+		Dependencies map[string]dependencyStatus `json:"dependencies"`
+	}{
+		Status:       status,
+		Env:          app.db.env,
+		Version:      version,
+		ShuttingDown: app.shuttingDown.Load(),
+		Dependencies: dependencies,
+	}
+
+	if err := writeJSON(w, code, data); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// checkReadiness pings every dependency concurrently, the API is ready when
+// all of them answer in time and it is not shutting down.
+func (app *application) checkReadiness(ctx context.Context) (bool, map[string]dependencyStatus) {
+	var (
+		mu           sync.Mutex
+		wg           sync.WaitGroup
+		ready        = !app.shuttingDown.Load()
+		dependencies = make(map[string]dependencyStatus, len(app.healthChecks))
+	)
+
+	for _, hc := range app.healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := hc.check(ctx)
+
+			status := dependencyStatus{
+				Status:    "up",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				status.Status = "down"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			dependencies[hc.name] = status
+			if err != nil {
+				ready = false
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ready, dependencies
+}
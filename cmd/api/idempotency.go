@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/MohummedSoliman/social/internal/store/cache"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// IdempotencyMiddleware makes retries of a request sent with the same
+// Idempotency-Key safe, the first response is stored and replayed for 24h.
+// Requests without the header are passed through untouched.
+func (app *application) IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			app.badRequest(w, r, clientError("Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_578))
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		key = idempotencyScope(r) + key
+		fingerprint := requestFingerprint(r, body)
+
+		stored, reserved, err := app.cacheStore.Idempotency.Reserve(ctx, key, fingerprint)
+		if errors.Is(err, cache.ErrUnavailable) {
+			// Rather than failing every write while Redis is down, serve the
+			// request without protection against retries.
+			app.requestLogger(r).Warn("idempotency key ignored, cache unavailable")
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if !reserved {
+			switch {
+			case stored.Fingerprint != fingerprint:
+				app.idempotencyKeyReusedResponse(w, r)
+			case !stored.Completed:
+				app.idempotencyInProgressResponse(w, r)
+			default:
+				replayResponse(w, stored)
+			}
+			return
+		}
+
+		var buf bytes.Buffer
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(&buf)
+
+		defer func() {
+			// The key must be settled even if the client went away.
+			ctx := context.WithoutCancel(ctx)
+
+			// Server errors, panics and handlers that never wrote are not
+			// remembered so the client can retry them.
+			p := recover()
+			if p != nil || ww.Status() == 0 || ww.Status() >= http.StatusInternalServerError {
+				if err := app.cacheStore.Idempotency.Release(ctx, key); err != nil {
+					app.requestLogger(r).Error("error releasing idempotency key", "error", err.Error())
+				}
+				if p != nil {
+					panic(p)
+				}
+				return
+			}
+
+			stored.Status = ww.Status()
+			stored.Header = map[string][]string{"Content-Type": ww.Header().Values("Content-Type")}
+			stored.Body = buf.Bytes()
+			if err := app.cacheStore.Idempotency.Complete(ctx, key, stored); err != nil {
+				app.requestLogger(r).Error("error storing idempotent response", "error", err.Error())
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// idempotencyScope keeps keys of different routes and users apart.
+func idempotencyScope(r *http.Request) string {
+	scope := r.Method + ":" + r.URL.Path + ":"
+	if user, ok := r.Context().Value(USERKEY).(*store.User); ok {
+		scope += strconv.FormatInt(user.ID, 10) + ":"
+	}
+	return scope
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, stored *cache.IdempotentRequest) {
+	for name, values := range stored.Header {
+		for _, v := range values {
+			w.Header().Add(name, v)
+		}
+	}
+	w.Header().Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
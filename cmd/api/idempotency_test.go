@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/MohummedSoliman/social/internal/store/cache"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	app.cacheStore = cache.NewMemoryStorage(cache.NewLRU(100, 0))
+
+	newRequest := func(key, body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(idempotencyKeyHeader, key)
+		return req
+	}
+
+	// serve runs the handler responses in turn, one per call.
+	serve := func(responses ...http.HandlerFunc) (http.Handler, *int) {
+		calls := new(int)
+		return app.IdempotencyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			(*calls)++
+			responses[min(*calls, len(responses))-1](w, r)
+		})), calls
+	}
+
+	created := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}
+
+	t.Run("Should replay the first response", func(t *testing.T) {
+		handler, calls := serve(created)
+
+		first := executeRequest(newRequest("replay", `{"title":"a"}`), handler)
+		checkResponseCode(t, http.StatusCreated, first.Code)
+
+		replayed := executeRequest(newRequest("replay", `{"title":"a"}`), handler)
+		checkResponseCode(t, http.StatusCreated, replayed.Code)
+
+		if *calls != 1 {
+			t.Errorf("Expected the handler to run once, but it ran %d times", *calls)
+		}
+		if replayed.Header().Get(idempotencyReplayedHeader) != "true" || replayed.Body.String() != `{"id":1}` {
+			t.Errorf("Expected the stored response to be replayed, but got %q", replayed.Body.String())
+		}
+	})
+
+	t.Run("Should reject a key reused for a different request", func(t *testing.T) {
+		handler, _ := serve(created)
+
+		executeRequest(newRequest("reused", `{"title":"a"}`), handler)
+		res := executeRequest(newRequest("reused", `{"title":"b"}`), handler)
+		checkResponseCode(t, http.StatusUnprocessableEntity, res.Code)
+	})
+
+	t.Run("Should reject a request while the first one is in flight", func(t *testing.T) {
+		handler, calls := serve(created)
+
+		req := newRequest("in-flight", `{"title":"a"}`)
+		key := idempotencyScope(req) + "in-flight"
+		if _, _, err := app.cacheStore.Idempotency.Reserve(context.Background(), key, requestFingerprint(req, []byte(`{"title":"a"}`))); err != nil {
+			t.Fatal(err)
+		}
+
+		res := executeRequest(req, handler)
+		checkResponseCode(t, http.StatusConflict, res.Code)
+		if *calls != 0 {
+			t.Errorf("Expected the handler not to run, but it ran %d times", *calls)
+		}
+	})
+
+	t.Run("Should release the key after a server error", func(t *testing.T) {
+		handler, calls := serve(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, created)
+
+		first := executeRequest(newRequest("server-error", `{}`), handler)
+		checkResponseCode(t, http.StatusServiceUnavailable, first.Code)
+
+		retry := executeRequest(newRequest("server-error", `{}`), handler)
+		checkResponseCode(t, http.StatusCreated, retry.Code)
+		if *calls != 2 {
+			t.Errorf("Expected the retry to run the handler again, but it ran %d times", *calls)
+		}
+	})
+
+	t.Run("Should release the key when the handler panics", func(t *testing.T) {
+		handler, calls := serve(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}, created)
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected the panic to propagate")
+				}
+			}()
+			executeRequest(newRequest("panic", `{}`), handler)
+		}()
+
+		retry := executeRequest(newRequest("panic", `{}`), handler)
+		checkResponseCode(t, http.StatusCreated, retry.Code)
+		if *calls != 2 {
+			t.Errorf("Expected the retry to run the handler again, but it ran %d times", *calls)
+		}
+	})
+
+	t.Run("Should release the key when the handler never writes", func(t *testing.T) {
+		handler, calls := serve(func(w http.ResponseWriter, r *http.Request) {}, created)
+
+		executeRequest(newRequest("no-write", `{}`), handler)
+
+		retry := executeRequest(newRequest("no-write", `{}`), handler)
+		checkResponseCode(t, http.StatusCreated, retry.Code)
+		if *calls != 2 {
+			t.Errorf("Expected the retry to run the handler again, but it ran %d times", *calls)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// purgeDeletedUsers periodically hard deletes the accounts whose deletion
+// grace period has expired, until ctx is cancelled.
+func (app *application) purgeDeletedUsers(ctx context.Context) {
+	ticker := time.NewTicker(app.config.account.purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := app.store.Users.PurgeDeleted(ctx, app.config.account.deletionGracePeriod)
+			if err != nil {
+				app.logger.Error("error purging deleted users", "error", err.Error())
+				continue
+			}
+
+			if purged.Users > 0 {
+				app.logger.Info("purged deleted users", "count", purged.Users)
+			}
+		}
+	}
+}
+
+// deliverWebhooks sends the due webhook deliveries every poll interval,
+// until ctx is cancelled. Deliveries are claimed in the database, so every
+// instance can run it.
+func (app *application) deliverWebhooks(ctx context.Context) {
+	ticker := time.NewTicker(app.config.webhooks.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deliveries, err := app.store.Webhooks.ClaimDue(ctx, app.config.webhooks.batchSize, app.config.webhooks.lease)
+			if err != nil {
+				app.logger.Error("error claiming webhook deliveries", "error", err.Error())
+				continue
+			}
+
+			var wg sync.WaitGroup
+			for _, delivery := range deliveries {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					app.sendWebhook(ctx, delivery)
+				}()
+			}
+			wg.Wait()
+		}
+	}
+}
@@ -4,14 +4,126 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/MohummedSoliman/social/internal/logger"
+	"github.com/MohummedSoliman/social/internal/metrics"
 	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+type requestInfoKey string
+
+const REQUESTINFOKEY requestInfoKey = "request-info"
+
+// requestInfo collects what inner handlers learn about a request, so the
+// access log written after the response can include it.
+type requestInfo struct {
+	userID int64
+}
+
+// RequestLoggerMiddleware stores a request scoped logger in the context and
+// writes one access log entry per request.
+func (app *application) RequestLoggerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		l := app.logger.With(
+			"request_id", middleware.GetReqID(r.Context()),
+			"trace_id", trace.SpanContextFromContext(r.Context()).TraceID().String(),
+			"method", r.Method,
+			"path", r.URL.Path,
+		)
+		info := &requestInfo{}
+
+		ctx := logger.WithContext(r.Context(), l)
+		ctx = context.WithValue(ctx, REQUESTINFOKEY, info)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			attrs := []any{
+				"route", chi.RouteContext(r.Context()).RoutePattern(),
+				"status", status,
+				"bytes", ww.BytesWritten(),
+				"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+				"remote_addr", r.RemoteAddr,
+			}
+			if info.userID != 0 {
+				attrs = append(attrs, "user_id", info.userID)
+			}
+
+			l.Info("request completed", attrs...)
+		}()
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+	})
+}
+
+// TracingMiddleware starts a server span for every request, continuing any
+// W3C trace context sent by the client, and names it after the chi route.
+func (app *application) TracingMiddleware(next http.Handler) http.Handler {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			return
+		}
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	})
+
+	return otelhttp.NewHandler(handler, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
+
+// MetricsMiddleware records request counts and latencies labelled by the
+// matched chi route pattern, so path parameters don't explode cardinality.
+func (app *application) MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+func (app *application) requestLogger(r *http.Request) *slog.Logger {
+	return logger.FromContext(r.Context())
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -79,19 +191,50 @@ func (app *application) AuthTokenMiddleware() func(http.Handler) http.Handler {
 
 			ctx := r.Context()
 
-			user, err := app.getUser(ctx, int64(userID))
+			user, err := app.store.Users.GetUserByID(ctx, int64(userID))
 			if err != nil {
 				app.unauthorizedError(w, r, err)
 				return
 			}
 
+			if user.IsSuspended() {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			if info, ok := ctx.Value(REQUESTINFOKEY).(*requestInfo); ok {
+				info.userID = user.ID
+			}
+
+			ctx = logger.WithContext(ctx, logger.FromContext(ctx).With("user_id", user.ID))
 			ctx = context.WithValue(ctx, USERKEY, user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func (app *application) checkPostOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+func (app *application) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromContext(r)
+
+			allowed, err := app.hasPermission(r.Context(), user, permission)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *application) checkPostOwnership(permission string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := getUserFromContext(r)
 		post := getPostFromContext(r)
@@ -101,7 +244,7 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 			return
 		}
 
-		allowed, err := app.checkRolePrecedence(r.Context(), user, requiredRole)
+		allowed, err := app.hasPermission(r.Context(), user, permission)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
@@ -116,39 +259,98 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 	})
 }
 
-func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, roleName string) (bool, error) {
-	role, err := app.store.Roles.GetByName(ctx, roleName)
-	if err != nil {
-		return false, err
-	}
+// primaryCookie carries until when a client reads from the primary after
+// writing, as a unix time in milliseconds.
+const primaryCookie = "read_primary_until"
+
+// ReadYourWritesMiddleware pins requests that may write to the primary, so
+// they never read stale rows from a lagging replica before or after writing.
+// The client is pinned for the read your writes window after that too, so
+// its next requests see the write even if the replicas did not catch up.
+func (app *application) ReadYourWritesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if app.pinnedToPrimary(r, now) {
+				r = r.WithContext(store.WithPrimary(r.Context()))
+			}
+		default:
+			r = r.WithContext(store.WithPrimary(r.Context()))
+
+			window := app.db.readYourWritesWindow
+			if window > 0 {
+				// only a write that succeeded pins the client.
+				pw := &pinPrimaryWriter{ResponseWriter: w, cookie: &http.Cookie{
+					Name:     primaryCookie,
+					Value:    strconv.FormatInt(now.Add(window).UnixMilli(), 10),
+					Path:     "/",
+					MaxAge:   int(window.Round(time.Second).Seconds()) + 1,
+					HttpOnly: true,
+					Secure:   app.db.env == "production",
+					SameSite: http.SameSiteLaxMode,
+				}}
+				next.ServeHTTP(pw, r)
 
-	return user.Role.Level >= role.Level, nil
+				// a handler that wrote nothing answers 200.
+				if !pw.wroteHeader {
+					pw.WriteHeader(http.StatusOK)
+				}
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
 
-func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
-	if !app.config.redisConfig.enabled {
-		return app.store.Users.GetUserByID(ctx, int64(userID))
+// pinPrimaryWriter sets its cookie with a 2xx status, the headers can no
+// longer be changed once it is written.
+type pinPrimaryWriter struct {
+	http.ResponseWriter
+	cookie      *http.Cookie
+	wroteHeader bool
+}
+
+func (w *pinPrimaryWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+		if status < http.StatusMultipleChoices {
+			http.SetCookie(w.ResponseWriter, w.cookie)
+		}
 	}
+	w.ResponseWriter.WriteHeader(status)
+}
 
-	user, err := app.cacheStore.Users.Get(ctx, userID)
-	if err != nil {
-		return nil, err
+func (w *pinPrimaryWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
 	}
+	return w.ResponseWriter.Write(b)
+}
 
-	// user does not exist in the cache.
-	if user == nil {
-		user, err = app.store.Users.GetUserByID(ctx, int64(userID))
-		if err != nil {
-			return nil, err
-		}
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *pinPrimaryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
 
-		err = app.cacheStore.Users.Set(ctx, user)
-		if err != nil {
-			return nil, err
-		}
+// pinnedToPrimary reports whether r comes from a client that wrote within
+// the read your writes window. Clients can send the cookie as they like, a
+// time further out than one window is ignored.
+func (app *application) pinnedToPrimary(r *http.Request, now time.Time) bool {
+	cookie, err := r.Cookie(primaryCookie)
+	if err != nil {
+		return false
+	}
+
+	ms, err := strconv.ParseInt(cookie.Value, 10, 64)
+	if err != nil {
+		return false
 	}
 
-	return user, nil
+	until := time.UnixMilli(ms)
+	return now.Before(until) && !until.After(now.Add(app.db.readYourWritesWindow))
 }
 
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
@@ -156,6 +358,7 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 		if app.config.rateLimiter.Enabled {
 			allow, retryAfter := app.ratelimiter.Allow(r.RemoteAddr)
 			if !allow {
+				metrics.RateLimiterRejections.Inc()
 				app.rateLimiterExceededResponse(w, r, retryAfter.String())
 				return
 			}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+func TestReadYourWritesMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	app.db.readYourWritesWindow = time.Second * 5
+
+	var pinned bool
+	status := http.StatusOK
+	handler := app.ReadYourWritesMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pinned = store.PinnedToPrimary(r.Context())
+		w.WriteHeader(status)
+	}))
+
+	serve := func(method string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/posts/1", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		return executeRequest(req, handler)
+	}
+
+	untilCookie := func(until time.Time) *http.Cookie {
+		return &http.Cookie{Name: primaryCookie, Value: strconv.FormatInt(until.UnixMilli(), 10)}
+	}
+
+	t.Run("Should read from the replicas by default", func(t *testing.T) {
+		serve(http.MethodGet)
+		if pinned {
+			t.Error("Expected the read not to be pinned to the primary")
+		}
+	})
+
+	t.Run("Should pin the reads following a write", func(t *testing.T) {
+		rec := serve(http.MethodPost)
+		if !pinned {
+			t.Error("Expected the write to be pinned to the primary")
+		}
+
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != primaryCookie {
+			t.Fatalf("Expected the %s cookie, but got %v", primaryCookie, cookies)
+		}
+
+		serve(http.MethodGet, cookies[0])
+		if !pinned {
+			t.Error("Expected the read after the write to be pinned to the primary")
+		}
+	})
+
+	t.Run("Should not pin the client after a failed write", func(t *testing.T) {
+		for _, status = range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError} {
+			rec := serve(http.MethodPost)
+			if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+				t.Errorf("Expected no cookie for status %d, but got %v", status, cookies)
+			}
+		}
+		status = http.StatusOK
+	})
+
+	t.Run("Should stop pinning once the window passed", func(t *testing.T) {
+		serve(http.MethodGet, untilCookie(time.Now().Add(-time.Second)))
+		if pinned {
+			t.Error("Expected an expired pin to be ignored")
+		}
+	})
+
+	t.Run("Should ignore pins beyond the window", func(t *testing.T) {
+		serve(http.MethodGet, untilCookie(time.Now().Add(time.Hour)))
+		if pinned {
+			t.Error("Expected a pin longer than the window to be ignored")
+		}
+	})
+}
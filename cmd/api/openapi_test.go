@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount().(chi.Routes)
+
+	mounted := make(map[string]bool)
+	err := chi.Walk(mux, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if !undocumentedRoutes[route] {
+			mounted[method+" "+route] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := make(map[string]bool)
+	for _, op := range buildOpenAPI().Operations() {
+		documented[op] = true
+	}
+
+	t.Run("Should document every mounted route", func(t *testing.T) {
+		for op := range mounted {
+			if !documented[op] {
+				t.Errorf("Route %s is mounted but missing from the OpenAPI spec", op)
+			}
+		}
+	})
+
+	t.Run("Should only document mounted routes", func(t *testing.T) {
+		for op := range documented {
+			if !mounted[op] {
+				t.Errorf("Route %s is in the OpenAPI spec but not mounted", op)
+			}
+		}
+	})
+}
+
+func TestOpenAPIIfMatch(t *testing.T) {
+	doc := buildOpenAPI()
+
+	t.Run("Should require If-Match on post writes", func(t *testing.T) {
+		for _, method := range []string{"patch", "delete"} {
+			op := doc.Paths["/v1/posts/{postID}"][method]
+
+			var required bool
+			for _, p := range op.Parameters {
+				if p.In == "header" && p.Name == "If-Match" {
+					required = p.Required
+				}
+			}
+			if !required {
+				t.Errorf("Expected If-Match to be a required header of %s", method)
+			}
+		}
+	})
+}
+
+func TestDocs(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.docsDir = t.TempDir()
+	mux := app.mount()
+
+	t.Run("Should report missing assets as not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/docs", nil)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	for _, name := range []string{"swagger-ui.css", "swagger-ui-bundle.js"} {
+		if err := os.WriteFile(filepath.Join(app.config.docsDir, name), []byte("/* "+name+" */"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Should load Swagger UI from the server", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/docs", nil)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if body := rr.Body.String(); strings.Contains(body, "https://") {
+			t.Errorf("Expected no external assets, got %s", body)
+		}
+	})
+
+	t.Run("Should serve the assets", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/docs/assets/swagger-ui-bundle.js", nil)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		if body := rr.Body.String(); body != "/* swagger-ui-bundle.js */" {
+			t.Errorf("Expected the bundle, got %q", body)
+		}
+	})
+}
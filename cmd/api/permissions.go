@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+const (
+	permUpdateAnyPost  = "posts:update:any"
+	permDeleteAnyPost  = "posts:delete:any"
+	permBanUsers       = "users:ban"
+	permManageRoles    = "roles:manage"
+	permReadAuditLog   = "audit:read"
+	permGlobalWebhooks = "webhooks:global"
+)
+
+const permissionCacheTTL = time.Minute * 5
+
+// permissionCache keeps the role -> permissions map in memory, so checks
+// only hit the database once per permissionCacheTTL.
+type permissionCache struct {
+	mu       sync.RWMutex
+	roles    map[int64]map[string]bool
+	loadedAt time.Time
+}
+
+func (c *permissionCache) has(ctx context.Context, roles store.Roles, roleID int64, permission string) (bool, error) {
+	c.mu.RLock()
+	perms := c.roles
+	fresh := perms != nil && time.Since(c.loadedAt) < permissionCacheTTL
+	c.mu.RUnlock()
+
+	if !fresh {
+		loaded, err := roles.GetPermissions(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		perms = make(map[int64]map[string]bool, len(loaded))
+		for id, names := range loaded {
+			perms[id] = make(map[string]bool, len(names))
+			for _, name := range names {
+				perms[id][name] = true
+			}
+		}
+
+		c.mu.Lock()
+		c.roles = perms
+		c.loadedAt = time.Now()
+		c.mu.Unlock()
+	}
+
+	return perms[roleID][permission], nil
+}
+
+func (app *application) hasPermission(ctx context.Context, user *store.User, permission string) (bool, error) {
+	return app.permissions.has(ctx, app.store.Roles, user.Role.ID, permission)
+}
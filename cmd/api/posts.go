@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/MohummedSoliman/social/internal/events"
 	"github.com/MohummedSoliman/social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -46,11 +50,28 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		UserID:  user.ID,
 	}
 
-	if err := app.store.Posts.Create(r.Context(), post); err != nil {
+	// the post and its webhook deliveries are committed together.
+	var event events.Event
+	err := app.store.Tx.InTx(r.Context(), nil, func(ctx context.Context) error {
+		if err := app.store.Posts.Create(ctx, post); err != nil {
+			return err
+		}
+
+		var err error
+		if event, err = newEvent(events.PostCreated, user.ID, post, user.ID); err != nil {
+			return err
+		}
+		return app.enqueueWebhooks(ctx, event)
+	})
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	app.broadcast(r, event)
+
+	// a new post has no comments, so this is the tag GET returns for it.
+	w.Header().Set("ETag", postETag(post))
 	if err := jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -64,7 +85,7 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
-			writeJSONError(w, http.StatusNotFound, err.Error())
+			app.notFoundError(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -73,6 +94,14 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 
 	post.Comments = comments
 
+	// the comments are part of the representation, so they are part of its tag.
+	etag := postETag(post)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	if err := jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -80,29 +109,38 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request) {
-	idParam := chi.URLParam(r, "postID")
-	postID, err := strconv.Atoi(idParam)
-	if err != nil {
-		app.internalServerError(w, r, err)
+	post := getPostFromContext(r)
+
+	if !app.checkIfMatch(w, r, post) {
+		return
 	}
 
-	err = app.store.Posts.DeletePostByID(r.Context(), int64(postID))
+	err := app.store.Posts.DeletePostByID(r.Context(), post.ID, post.Version)
 	if err != nil {
 		switch {
-		case errors.Is(err, store.ErrNotFound):
-			app.notFoundError(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
+	user := getUserFromContext(r)
+	if user.ID != post.UserID {
+		app.audit(r, user.ID, auditPostDelete, auditTargetPost, post.ID, post, nil)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromContext(r)
 
+	if !app.checkIfMatch(w, r, post) {
+		return
+	}
+
 	var payload UpdatePostPayload
 
 	err := readJSON(w, r, &payload)
@@ -116,6 +154,8 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	before := *post
+
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
@@ -125,43 +165,127 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := app.store.Posts.UpdatePost(r.Context(), post); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.preconditionFailedResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user := getUserFromContext(r)
+	if user.ID != post.UserID {
+		app.audit(r, user.ID, auditPostUpdate, auditTargetPost, post.ID, before, post)
+	}
+
+	// the post is returned with its comments, as GET returns it, so both
+	// give the same tag.
+	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	post.Comments = comments
 
+	w.Header().Set("ETag", postETag(post))
 	if err := jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
 
-// func (app *application) postContextMiddleware(next http.Handler) http.Handler {
-// 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-// 		idParam := chi.URLParam(r, "postID")
-
-// 		postID, err := strconv.Atoi(idParam)
-// 		if err != nil {
-// 			app.internalServerError(w, r, err)
-// 			return
-// 		}
-
-// 		post, err := app.store.Posts.GetPostByID(r.Context(), postID)
-// 		if err != nil {
-// 			switch {
-// 			case errors.Is(err, store.ErrNotFound):
-// 				writeJSONError(w, http.StatusNotFound, err.Error())
-// 			default:
-// 				app.internalServerError(w, r, err)
-// 			}
-// 			return
-// 		}
-
-// 		ctx := context.WithValue(r.Context(), POSTKEY, post)
-// 		next.ServeHTTP(w, r.WithContext(ctx))
-// 	})
-// }
+func (app *application) postContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		idParam := chi.URLParam(r, "postID")
+
+		postID, err := strconv.Atoi(idParam)
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		post, err := app.store.Posts.GetPostByID(r.Context(), postID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundError(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), POSTKEY, post)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
 func getPostFromContext(r *http.Request) *store.Post {
 	post := r.Context().Value(POSTKEY).(*store.Post)
 	return post
 }
+
+// postETag derives the entity tag of a post from its version, which is
+// bumped on every update, and from its loaded comments, which are only
+// ever added.
+func postETag(post *store.Post) string {
+	var lastCommentID int64
+	for _, c := range post.Comments {
+		lastCommentID = max(lastCommentID, c.ID)
+	}
+	return fmt.Sprintf(`"%d-%d-%d"`, post.Version, len(post.Comments), lastCommentID)
+}
+
+// checkIfMatch requires the If-Match header on writes, so a client can only
+// change the version of the post it has seen.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, post *store.Post) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		app.preconditionRequiredResponse(w, r)
+		return false
+	}
+
+	if !ifMatchVersion(ifMatch, post.Version) {
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+// etagMatches reports whether etag is in the comma separated header list.
+// It is the weak comparison of If-None-Match, so weak validators match as
+// well.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion reports whether a tag in the If-Match header list was
+// given for version. It is the strong comparison If-Match requires, weak
+// validators never match. Comments do not conflict with writes to the
+// post, so only the version part of the tag is compared.
+func ifMatchVersion(header string, version int) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		tag, ok := strings.CutPrefix(candidate, `"`)
+		if !ok {
+			continue
+		}
+		v, _, _ := strings.Cut(strings.TrimSuffix(tag, `"`), "-")
+		if n, err := strconv.Atoi(v); err == nil && n == version {
+			return true
+		}
+	}
+	return false
+}
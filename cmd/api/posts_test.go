@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{``, false},
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"2", "3"`, true},
+		{`*`, true},
+		{`"4"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run("Should compare "+tt.header, func(t *testing.T) {
+			if got := etagMatches(tt.header, `"3"`); got != tt.want {
+				t.Errorf("Expected %v for %q, but got %v", tt.want, tt.header, got)
+			}
+		})
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{``, false},
+		{`"3-0-0"`, true},
+		{`"3-2-17"`, true},
+		{`W/"3-0-0"`, false},
+		{`"2-0-0", "3-1-5"`, true},
+		{`*`, true},
+		{`"4-0-0"`, false},
+		{`"30-0-0"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run("Should compare "+tt.header, func(t *testing.T) {
+			if got := ifMatchVersion(tt.header, 3); got != tt.want {
+				t.Errorf("Expected %v for %q, but got %v", tt.want, tt.header, got)
+			}
+		})
+	}
+}
+
+func TestUpdatePost(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.mount()
+	testToken, _ := app.authenticator.GenerateToken(nil)
+
+	newRequest := func(method, url, body string) *http.Request {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	reqRec := executeRequest(newRequest(http.MethodPost, "/v1/posts", `{"title": "title", "content": "content"}`), mux)
+	checkResponseCode(t, http.StatusCreated, reqRec.Code)
+
+	var created struct {
+		Data store.Post `json:"data"`
+	}
+	if err := json.NewDecoder(reqRec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	url := "/v1/posts/" + strconv.FormatInt(created.Data.ID, 10)
+
+	t.Run("Should require the current version", func(t *testing.T) {
+		reqRec := executeRequest(newRequest(http.MethodGet, url, ""), mux)
+		checkResponseCode(t, http.StatusOK, reqRec.Code)
+		etag := reqRec.Header().Get("ETag")
+
+		req := newRequest(http.MethodPatch, url, `{"title": "updated"}`)
+		req.Header.Set("If-Match", etag)
+		reqRec = executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, reqRec.Code)
+
+		req = newRequest(http.MethodPatch, url, `{"title": "stale"}`)
+		req.Header.Set("If-Match", etag)
+		reqRec = executeRequest(req, mux)
+		checkResponseCode(t, http.StatusPreconditionFailed, reqRec.Code)
+	})
+	t.Run("Should revalidate after a comment is added", func(t *testing.T) {
+		reqRec := executeRequest(newRequest(http.MethodGet, url, ""), mux)
+		etag := reqRec.Header().Get("ETag")
+
+		req := newRequest(http.MethodGet, url, "")
+		req.Header.Set("If-None-Match", etag)
+		checkResponseCode(t, http.StatusNotModified, executeRequest(req, mux).Code)
+
+		reqRec = executeRequest(newRequest(http.MethodPost, url+"/comments", `{"content": "comment"}`), mux)
+		checkResponseCode(t, http.StatusCreated, reqRec.Code)
+
+		req = newRequest(http.MethodGet, url, "")
+		req.Header.Set("If-None-Match", etag)
+		checkResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+
+		// the post itself did not change, so the tag still allows writes.
+		req = newRequest(http.MethodPatch, url, `{"title": "after comment"}`)
+		req.Header.Set("If-Match", etag)
+		checkResponseCode(t, http.StatusOK, executeRequest(req, mux).Code)
+	})
+
+	t.Run("Should return the tag of GET from PATCH", func(t *testing.T) {
+		reqRec := executeRequest(newRequest(http.MethodGet, url, ""), mux)
+		etag := reqRec.Header().Get("ETag")
+
+		req := newRequest(http.MethodPatch, url, `{"title": "tagged"}`)
+		req.Header.Set("If-Match", etag)
+		reqRec = executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, reqRec.Code)
+		patched := reqRec.Header().Get("ETag")
+
+		req = newRequest(http.MethodGet, url, "")
+		req.Header.Set("If-None-Match", patched)
+		checkResponseCode(t, http.StatusNotModified, executeRequest(req, mux).Code)
+	})
+	t.Run("Should only delete the current version", func(t *testing.T) {
+		reqRec := executeRequest(newRequest(http.MethodGet, url, ""), mux)
+		etag := reqRec.Header().Get("ETag")
+
+		req := newRequest(http.MethodPatch, url, `{"title": "before delete"}`)
+		req.Header.Set("If-Match", etag)
+		reqRec = executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, reqRec.Code)
+
+		req = newRequest(http.MethodDelete, url, "")
+		req.Header.Set("If-Match", etag)
+		checkResponseCode(t, http.StatusPreconditionFailed, executeRequest(req, mux).Code)
+
+		req = newRequest(http.MethodDelete, url, "")
+		req.Header.Set("If-Match", reqRec.Header().Get("ETag"))
+		checkResponseCode(t, http.StatusNoContent, executeRequest(req, mux).Code)
+	})
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/events"
+	"github.com/MohummedSoliman/social/internal/store"
+	"golang.org/x/net/websocket"
+)
+
+// streamHeartbeat keeps idle SSE connections open through proxies.
+const streamHeartbeat = time.Second * 15
+
+type streamQuery struct {
+	AccessToken string `json:"access_token"`
+}
+
+type streamMessage struct {
+	ID        string          `json:"id"`
+	Type      events.Type     `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// publish notifies the stream clients and webhooks of a committed change.
+// Failing to publish is logged and never fails the request.
+func (app *application) publish(r *http.Request, t events.Type, actorID int64, data any, followersOf int64, recipients ...int64) {
+	event, err := newEvent(t, actorID, data, followersOf, recipients...)
+	if err != nil {
+		app.requestLogger(r).Error("error encoding event", "type", t, "error", err.Error())
+		return
+	}
+
+	if err := app.enqueueWebhooks(r.Context(), event); err != nil {
+		app.requestLogger(r).Error("error enqueuing webhooks", "type", t, "error", err.Error())
+	}
+
+	app.broadcast(r, event)
+}
+
+// broadcast notifies the stream clients of event once its change is
+// committed, its webhooks are enqueued apart.
+func (app *application) broadcast(r *http.Request, event events.Event) {
+	if err := app.events.Publish(r.Context(), event); err != nil {
+		app.requestLogger(r).Error("error publishing event", "type", event.Type, "error", err.Error())
+	}
+}
+
+func newEvent(t events.Type, actorID int64, data any, followersOf int64, recipients ...int64) (events.Event, error) {
+	event, err := events.New(t, actorID, data)
+	if err != nil {
+		return events.Event{}, err
+	}
+
+	event.FollowersOf = followersOf
+	event.Recipients = recipients
+	return event, nil
+}
+
+// StreamTokenMiddleware lets browser clients, which cannot set headers on
+// EventSource and WebSocket requests, pass the JWT as access_token.
+func (app *application) StreamTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) streamHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	filter, err := app.newStreamFilter(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// Subscribing before the response is started means a client never misses
+	// the events published once it sees the stream open.
+	sub := app.events.Subscribe()
+	defer sub.Close()
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		app.streamWebSocket(w, r, sub, filter)
+		return
+	}
+
+	app.streamSSE(w, r, sub, filter)
+}
+
+func (app *application) streamSSE(w http.ResponseWriter, r *http.Request, sub *events.Subscription, filter *streamFilter) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok || filter.ends(event) {
+				return
+			}
+			if !filter.allows(event) {
+				continue
+			}
+
+			var data []byte
+			data, err = json.Marshal(newStreamMessage(event))
+			if err != nil {
+				app.requestLogger(r).Error("error encoding event", "error", err.Error())
+				continue
+			}
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (app *application) streamWebSocket(w http.ResponseWriter, r *http.Request, sub *events.Subscription, filter *streamFilter) {
+	handler := func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		// The server timeouts are meant for regular requests.
+		if err := ws.SetDeadline(time.Time{}); err != nil {
+			return
+		}
+
+		// Clients are not expected to send anything, reading only notices
+		// when they go away.
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			var msg string
+			for websocket.Message.Receive(ws, &msg) == nil {
+			}
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case event, ok := <-sub.Events():
+				if !ok || filter.ends(event) {
+					return
+				}
+				if !filter.allows(event) {
+					continue
+				}
+				if err := websocket.JSON.Send(ws, newStreamMessage(event)); err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	// Clients authenticate with a token instead of cookies, so requests from
+	// any origin are accepted like the CORS configuration does.
+	websocket.Server{Handler: handler}.ServeHTTP(w, r)
+}
+
+func newStreamMessage(event events.Event) streamMessage {
+	return streamMessage{
+		ID:        event.ID,
+		Type:      event.Type,
+		Data:      event.Data,
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+// streamFilter decides which events a connected user is allowed to see.
+type streamFilter struct {
+	userID    int64
+	following map[int64]bool
+}
+
+func (app *application) newStreamFilter(ctx context.Context, userID int64) (*streamFilter, error) {
+	following, err := app.store.Followers.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &streamFilter{userID: userID, following: make(map[int64]bool)}
+	for _, f := range following {
+		filter.following[f.UserID] = true
+	}
+	return filter, nil
+}
+
+// ends reports whether the stream must be closed, once its user was
+// suspended or banned.
+func (f *streamFilter) ends(event events.Event) bool {
+	return event.Type == events.UserSuspended && slices.Contains(event.Recipients, f.userID)
+}
+
+func (f *streamFilter) allows(event events.Event) bool {
+	// Users followed or unfollowed after connecting are seen from then on,
+	// or no longer.
+	if (event.Type == events.Follow || event.Type == events.Unfollow) && event.ActorID == f.userID {
+		var follow store.Follower
+		if err := json.Unmarshal(event.Data, &follow); err == nil {
+			if event.Type == events.Follow {
+				f.following[follow.UserID] = true
+			} else {
+				delete(f.following, follow.UserID)
+			}
+		}
+	}
+
+	if event.ActorID == f.userID || slices.Contains(event.Recipients, f.userID) {
+		return true
+	}
+	return event.FollowersOf != 0 && f.following[event.FollowersOf]
+}
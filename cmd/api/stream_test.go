@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/events"
+	"github.com/MohummedSoliman/social/internal/store"
+	"golang.org/x/net/websocket"
+)
+
+func TestStreamFilter(t *testing.T) {
+	newEvent := func(t *testing.T, typ events.Type, actorID int64, data any, followersOf int64, recipients ...int64) events.Event {
+		t.Helper()
+		event, err := newEvent(typ, actorID, data, followersOf, recipients...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	t.Run("Should allow own events and events sent to the user", func(t *testing.T) {
+		filter := &streamFilter{userID: 42, following: map[int64]bool{}}
+
+		if !filter.allows(newEvent(t, events.PostCreated, 42, nil, 42)) {
+			t.Error("expected the user's own event to be allowed")
+		}
+		if !filter.allows(newEvent(t, events.CommentCreated, 7, nil, 0, 42)) {
+			t.Error("expected an event listing the user as recipient to be allowed")
+		}
+		if filter.allows(newEvent(t, events.CommentCreated, 7, nil, 0, 8)) {
+			t.Error("expected an event for another recipient to be filtered")
+		}
+	})
+
+	t.Run("Should only allow events of followed users", func(t *testing.T) {
+		filter := &streamFilter{userID: 42, following: map[int64]bool{7: true}}
+
+		if !filter.allows(newEvent(t, events.PostCreated, 7, nil, 7)) {
+			t.Error("expected an event of a followed user to be allowed")
+		}
+		if filter.allows(newEvent(t, events.PostCreated, 8, nil, 8)) {
+			t.Error("expected an event of a user not followed to be filtered")
+		}
+	})
+
+	t.Run("Should allow users followed after connecting", func(t *testing.T) {
+		filter := &streamFilter{userID: 42, following: map[int64]bool{}}
+
+		follow := newEvent(t, events.Follow, 42, store.Follower{UserID: 8, FollowerID: 42}, 0, 8)
+		if !filter.allows(follow) {
+			t.Error("expected the user's own follow to be allowed")
+		}
+		if !filter.allows(newEvent(t, events.PostCreated, 8, nil, 8)) {
+			t.Error("expected an event of the newly followed user to be allowed")
+		}
+	})
+
+	t.Run("Should stop allowing users unfollowed after connecting", func(t *testing.T) {
+		filter := &streamFilter{userID: 42, following: map[int64]bool{8: true}}
+
+		unfollow := newEvent(t, events.Unfollow, 42, store.Follower{UserID: 8, FollowerID: 42}, 0, 8)
+		if !filter.allows(unfollow) {
+			t.Error("expected the user's own unfollow to be allowed")
+		}
+		if filter.allows(newEvent(t, events.PostCreated, 8, nil, 8)) {
+			t.Error("expected an event of the unfollowed user to be filtered")
+		}
+	})
+
+	t.Run("Should end the stream of a suspended user", func(t *testing.T) {
+		filter := &streamFilter{userID: 42, following: map[int64]bool{}}
+
+		if filter.ends(newEvent(t, events.UserSuspended, 1, nil, 0, 8)) {
+			t.Error("expected the suspension of another user to keep the stream")
+		}
+		if !filter.ends(newEvent(t, events.UserSuspended, 1, nil, 0, 42)) {
+			t.Error("expected the suspension of the user to end the stream")
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	app := newTestApplication(t)
+	testToken, _ := app.authenticator.GenerateToken(nil)
+
+	ctx := context.Background()
+	for _, id := range []int64{7, 8} {
+		name := "user" + strconv.FormatInt(id, 10)
+		user := &store.User{ID: id, Username: name, Email: name + "@example.com", RoleID: 1}
+		if err := app.store.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.store.Followers.Follow(ctx, 42, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	// done reports when a stream handler returned.
+	done := make(chan struct{}, 1)
+	mux := app.mount()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		if r.URL.Path == "/v1/stream" {
+			done <- struct{}{}
+		}
+	}))
+	defer srv.Close()
+
+	publish := func(t *testing.T, actorID int64) events.Event {
+		t.Helper()
+		event, err := newEvent(events.PostCreated, actorID, map[string]int64{"user_id": actorID}, actorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.events.Publish(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	waitDone := func(t *testing.T) {
+		t.Helper()
+		select {
+		case <-done:
+		case <-time.After(time.Second * 5):
+			t.Fatal("the stream handler did not return")
+		}
+	}
+
+	t.Run("Should not allow unauthenticated requests", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/v1/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		waitDone(t)
+
+		checkResponseCode(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("Should reject an invalid access token", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/v1/stream?access_token=invalid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		waitDone(t)
+
+		checkResponseCode(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("Should send the events of followed users over SSE", func(t *testing.T) {
+		reqCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, srv.URL+"/v1/stream?access_token="+testToken, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+		if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("expected an event stream, got %q", ct)
+		}
+
+		publish(t, 8)
+		want := publish(t, 7)
+
+		line, err := bufio.NewReader(res.Body).ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(line); got != "id: "+want.ID {
+			t.Errorf("expected the followed user's event %q, got %q", want.ID, got)
+		}
+
+		cancel()
+		waitDone(t)
+	})
+
+	t.Run("Should send the events of followed users over WebSocket", func(t *testing.T) {
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/v1/stream?access_token=" + testToken
+		ws, err := websocket.Dial(url, "", srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ws.Close()
+
+		publish(t, 8)
+		want := publish(t, 7)
+
+		if err := ws.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
+			t.Fatal(err)
+		}
+		var msg streamMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID != want.ID {
+			t.Errorf("expected the followed user's event %q, got %q", want.ID, msg.ID)
+		}
+
+		ws.Close()
+		waitDone(t)
+	})
+
+	t.Run("Should close the stream when the user is suspended", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/stream?access_token="+testToken, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		event, err := newEvent(events.UserSuspended, 1, map[string]int64{"user_id": 42}, 0, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.events.Publish(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t)
+	})
+
+	t.Run("Should close the streams when the server shuts down", func(t *testing.T) {
+		hubCtx, cancel := context.WithCancel(ctx)
+		hubDone := make(chan error, 1)
+		go func() { hubDone <- app.events.Run(hubCtx) }()
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/stream?access_token="+testToken, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		checkResponseCode(t, http.StatusOK, res.StatusCode)
+
+		cancel()
+		if err := <-hubDone; err != nil {
+			t.Fatal(err)
+		}
+		waitDone(t)
+	})
+}
@@ -1,25 +1,36 @@
 package main
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/MohummedSoliman/social/internal/auth"
+	"github.com/MohummedSoliman/social/internal/events"
 	"github.com/MohummedSoliman/social/internal/store"
 	"github.com/MohummedSoliman/social/internal/store/cache"
 )
 
 func newTestApplication(t *testing.T) *application {
 	t.Helper()
-	mockStore := store.NewMockStore()
+	testStore := store.NewMemoryStorage()
 	mockCacheStore := cache.NewMockCacheStorage()
 	testAuth := auth.NewTestAuthenticator()
 
+	// the test authenticator issues tokens for user 42.
+	testUser := &store.User{ID: 42, Username: "gopher", Email: "gopher@example.com", RoleID: 1}
+	if err := testStore.Users.Create(context.Background(), testUser); err != nil {
+		t.Fatal(err)
+	}
+
 	return &application{
-		store:         mockStore,
+		store:         testStore,
 		cacheStore:    mockCacheStore,
 		authenticator: testAuth,
+		events:        events.NewHub(),
+		logger:        slog.New(slog.DiscardHandler),
 	}
 }
 
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/MohummedSoliman/social/internal/tracing"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracing.SetupInMemory()
+
+	app := newTestApplication(t)
+	mux := app.mount()
+	testToken, _ := app.authenticator.GenerateToken(nil)
+
+	t.Run("Should name server spans after the route and continue the client trace", func(t *testing.T) {
+		exporter.Reset()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		reqRec := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, reqRec.Code)
+
+		spans := exporter.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("Expected 1 span, but got %d", len(spans))
+		}
+
+		if spans[0].Name != "GET /v1/users/{userID}" {
+			t.Errorf("Expected span name %q, but got %q", "GET /v1/users/{userID}", spans[0].Name)
+		}
+
+		if traceID := spans[0].SpanContext.TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected trace id from traceparent header, but got %s", traceID)
+		}
+	})
+}
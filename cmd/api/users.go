@@ -1,11 +1,17 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/MohummedSoliman/social/internal/events"
+	"github.com/MohummedSoliman/social/internal/mailer"
 	"github.com/MohummedSoliman/social/internal/store"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 )
 
 type userContextKeys string
@@ -35,6 +41,9 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	follow := store.Follower{UserID: followedID, FollowerID: followerUser.ID}
+	app.publish(r, events.Follow, followerUser.ID, follow, 0, followedID)
+
 	if err := jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -56,6 +65,119 @@ func (app *application) unFollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	unfollow := store.Follower{UserID: unfollowedID, FollowerID: unfollowedUser.ID}
+	app.publish(r, events.Unfollow, unfollowedUser.ID, unfollow, 0, unfollowedID)
+
+	if err := jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type ChangeEmailPayload struct {
+	Email string `json:"email" validate:"required,email,max=100"`
+}
+
+func (app *application) requestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	if payload.Email == user.Email {
+		app.badRequest(w, r, clientError("new email is the same as the current one"))
+		return
+	}
+
+	token := uuid.New().String()
+	hash := sha256.Sum256([]byte(token))
+	hashedToken := hex.EncodeToString(hash[:])
+
+	err := app.store.Users.RequestEmailChange(r.Context(), user.ID, payload.Email, hashedToken, app.config.mail.emailChangeExpiry)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	isProdEnv := app.db.env == "production"
+
+	confirmVars := struct {
+		Username        string
+		ConfirmationURL string
+	}{
+		Username:        user.Username,
+		ConfirmationURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, token),
+	}
+
+	err = app.mailer.Send(mailer.EmailChangeTemplate, user.Username, payload.Email, confirmVars, !isProdEnv)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	noticeVars := struct {
+		Username string
+		NewEmail string
+	}{
+		Username: user.Username,
+		NewEmail: payload.Email,
+	}
+
+	// the notice is informative only, the change can still be confirmed without it.
+	err = app.mailer.Send(mailer.EmailNoticeTemplate, user.Username, user.Email, noticeVars, !isProdEnv)
+	if err != nil {
+		app.requestLogger(r).Error("error sending email change notice", "error", err.Error())
+	}
+
+	if err := jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	_, err := app.store.Users.ConfirmEmailChange(r.Context(), token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequest(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.badRequest(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := jsonResponse(w, http.StatusNoContent, nil); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	err := app.store.Users.SoftDelete(r.Context(), user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
 	if err := jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/events"
+	"github.com/MohummedSoliman/social/internal/metrics"
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/MohummedSoliman/social/internal/webhook"
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateWebhookPayload struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=255"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=post.created comment.created user.followed user.unfollowed"`
+	Global bool     `json:"global"`
+}
+
+// WebhookWithSecret is only returned when a webhook is created, the secret
+// can not be read back afterwards.
+type WebhookWithSecret struct {
+	*store.Webhook
+	Secret string `json:"secret"`
+}
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateWebhookPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := webhook.CheckURL(r.Context(), payload.URL); err != nil {
+		switch {
+		case errors.Is(err, webhook.ErrInvalidURL):
+			app.badRequest(w, r, clientError("url must be an http or https URL"))
+		case errors.Is(err, webhook.ErrAddressForbidden):
+			app.badRequest(w, r, clientError("url must resolve to a public address"))
+		default:
+			app.badRequest(w, r, clientError("url host could not be resolved"))
+		}
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if payload.Global {
+		allowed, err := app.hasPermission(r.Context(), user, permGlobalWebhooks)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if payload.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		payload.Secret = secret
+	}
+
+	hook := &store.Webhook{
+		UserID: user.ID,
+		URL:    payload.URL,
+		Secret: payload.Secret,
+		Events: payload.Events,
+		Global: payload.Global,
+	}
+
+	if err := app.store.Webhooks.Create(r.Context(), hook); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := jsonResponse(w, http.StatusCreated, WebhookWithSecret{Webhook: hook, Secret: hook.Secret}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	webhooks, err := app.store.Webhooks.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := jsonResponse(w, http.StatusOK, webhooks); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Webhooks.Delete(r.Context(), webhookID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	q := store.WebhookDeliveryQuery{
+		Limit:  50,
+		Offset: 0,
+	}
+
+	q, err = q.Parse(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	deliveries, err := app.store.Webhooks.GetDeliveries(r.Context(), webhookID, user.ID, q)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := jsonResponse(w, http.StatusOK, deliveries); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "webhookID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+
+	if err := app.store.Webhooks.Redeliver(r.Context(), deliveryID, webhookID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// enqueueWebhooks queues a delivery of event for every webhook subscribed
+// to it. The deliveries are sent by deliverWebhooks. Called inside
+// Transactor.InTx, the deliveries commit with the change of event.
+func (app *application) enqueueWebhooks(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(newStreamMessage(event))
+	if err != nil {
+		return err
+	}
+
+	_, err = app.store.Webhooks.Enqueue(ctx, store.WebhookEvent{
+		ID:          event.ID,
+		Type:        string(event.Type),
+		ActorID:     event.ActorID,
+		Recipients:  event.Recipients,
+		FollowersOf: event.FollowersOf,
+		Payload:     payload,
+	})
+	return err
+}
+
+// sendWebhook makes one attempt at delivery and records the outcome,
+// scheduling a retry with backoff until maxAttempts is reached.
+func (app *application) sendWebhook(ctx context.Context, delivery *store.WebhookDelivery) {
+	res, err := app.webhooks.Send(ctx, webhook.Request{
+		URL:        delivery.URL,
+		Secret:     delivery.Secret,
+		DeliveryID: delivery.ID,
+		Event:      delivery.EventType,
+		Payload:    delivery.Payload,
+	})
+
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+	delivery.LastError = ""
+
+	if err != nil {
+		app.logger.Warn("error sending webhook", "delivery_id", delivery.ID, "error", err.Error())
+		delivery.LastError = deliveryError(err)
+	} else {
+		delivery.ResponseStatus = &res.Status
+		if !res.OK() {
+			delivery.LastError = "receiver responded with status " + strconv.Itoa(res.Status)
+		}
+	}
+
+	var outcome string
+	switch {
+	case err == nil && res.OK():
+		delivery.Status = store.DeliverySucceeded
+		outcome = "succeeded"
+	case delivery.Attempts >= app.config.webhooks.maxAttempts:
+		delivery.Status = store.DeliveryFailed
+		outcome = "failed"
+	default:
+		delivery.Status = store.DeliveryPending
+		delivery.NextAttemptAt = time.Now().Add(webhook.Backoff(delivery.Attempts))
+		outcome = "retried"
+	}
+	metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+
+	if err := app.store.Webhooks.RecordAttempt(ctx, delivery); err != nil {
+		app.logger.Error("error recording webhook delivery", "delivery_id", delivery.ID, "error", err.Error())
+	}
+}
+
+// deliveryError is what the delivery log shows for a failed attempt. The
+// error itself may reveal how the server sees the network, so only its
+// class is recorded.
+func deliveryError(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, webhook.ErrAddressForbidden):
+		return "receiver address is not allowed"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "request failed"
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS user_email_changes (
+    token bytea PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    new_email citext NOT NULL,
+    expiry TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
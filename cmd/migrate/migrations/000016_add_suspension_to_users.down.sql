@@ -0,0 +1,5 @@
+UPDATE roles SET level = 2 WHERE name = 'admin';
+
+ALTER TABLE users DROP COLUMN IF EXISTS suspension_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_until;
+ALTER TABLE users DROP COLUMN IF EXISTS suspended_at;
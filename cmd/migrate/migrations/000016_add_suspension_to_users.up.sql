@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP(0) WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP(0) WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspension_reason TEXT;
+
+-- admin has to outrank moderator for admin only endpoints.
+UPDATE roles SET level = 3 WHERE name = 'admin';
@@ -3,30 +3,46 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", addr)
+// New connects a pgx pool to addr. minConns connections are kept open
+// even while idle, the others are closed after maxIdleTime. Code written
+// against database/sql runs on the pool with stdlib.OpenDBFromPool.
+func New(addr string, maxOpenConns, minConns int, maxIdleTime string) (*pgxpool.Pool, error) {
+	pool, err := Open(addr, maxOpenConns, minConns, maxIdleTime)
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(maxOpenConns)
-	db.SetMaxIdleConns(maxIdleConns)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	duration, err := time.ParseDuration(maxIdleTime)
-	if err != nil {
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, err
 	}
-	db.SetConnMaxIdleTime(duration)
+	return pool, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Open is New without connecting, for a database that may be down for now.
+// It only fails on an invalid configuration.
+func Open(addr string, maxOpenConns, minConns int, maxIdleTime string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(addr)
+	if err != nil {
+		return nil, err
+	}
 
-	if err = db.PingContext(ctx); err != nil {
+	duration, err := time.ParseDuration(maxIdleTime)
+	if err != nil {
 		return nil, err
 	}
-	return db, nil
+
+	config.MaxConns = int32(maxOpenConns)
+	config.MinConns = int32(min(minConns, maxOpenConns))
+	config.MaxConnIdleTime = duration
+
+	return pgxpool.NewWithConfig(context.Background(), config)
 }
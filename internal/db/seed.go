@@ -2,111 +2,321 @@ package db
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
 
-	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
 )
 
-var usernames = []string{
-	"brave_tiger", "cool_dragon", "silent_wolf", "fast_eagle", "wild_fox", "smart_lion", "bright_bear",
+var adjectives = []string{
+	"brave", "cool", "silent", "fast", "wild", "smart", "bright", "calm", "lucky", "happy",
+	"swift", "bold", "quiet", "clever", "eager", "gentle", "proud", "sunny", "witty", "fierce",
 }
 
-var titles = []string{
-	"admin", "editor", "viewer", "guest", "author", "manager", "owner",
+var animals = []string{
+	"tiger", "dragon", "wolf", "eagle", "fox", "lion", "bear", "otter", "falcon", "panda",
+	"gopher", "whale", "raven", "lynx", "koala", "shark", "owl", "bison", "heron", "badger",
 }
 
-var contents = []string{
-	"daily report", "project update", "team meeting", "new feature", "bug fix", "code review", "design mockup",
+var words = []string{
+	"daily", "report", "project", "update", "team", "meeting", "new", "feature", "bug", "fix",
+	"code", "review", "design", "mockup", "release", "notes", "deploy", "server", "latency", "cache",
+	"query", "index", "refactor", "test", "coverage", "benchmark", "profile", "memory", "goroutine", "channel",
 }
 
 var tags = []string{
-	"golang", "backend", "database", "api", "cloud", "docker", "performance",
+	"golang", "backend", "database", "api", "cloud", "docker", "performance", "kubernetes", "testing", "security",
+	"postgres", "redis", "frontend", "devops", "linux", "networking", "career", "opensource", "design", "tutorial",
 }
 
-// var comments = []string{
-// 	"Great work on this!",
-// 	"I think we should review this part.",
-// 	"Looks good to me.",
-// 	"Can we optimize this section?",
-// 	"Please double-check the logic here.",
-// 	"Nice improvement over the last version.",
-// 	"Let’s discuss this in the next meeting.",
-// }
+// SeedConfig sets the volume and shape of the seeded data. The same Seed
+// generates the same data, with timestamps relative to the seeding time.
+type SeedConfig struct {
+	Users    int
+	Posts    int
+	Comments int
+	// Follows is the average number of users each user follows.
+	Follows int
+	// Skew is the exponent of the power laws of the follower graph and of
+	// how active users are, it must be greater than 1.
+	Skew      float64
+	Span      time.Duration
+	Seed      uint64
+	BatchSize int
+	Password  string
+}
+
+// Validate reports a configuration Seed would reject.
+func (cfg SeedConfig) Validate() error {
+	if cfg.Users < 2 || cfg.Skew <= 1 || cfg.BatchSize < 1 {
+		return errors.New("seeding needs at least 2 users, a skew above 1 and a positive batch size")
+	}
+	if cfg.Posts < 0 || cfg.Comments < 0 || cfg.Follows < 0 {
+		return errors.New("the number of posts, comments and follows can not be negative")
+	}
+	return nil
+}
 
-const numberOfSeedData = 7
+// seedDB is the part of a pgx transaction the seeder uses.
+type seedDB interface {
+	CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
 
-func Seed(store store.Storage, db *sql.DB) {
-	ctx := context.Background()
+type seeder struct {
+	db  seedDB
+	cfg SeedConfig
+	r   *rand.Rand
+	now time.Time
 
-	users := generateUsers()
-	tx, _ := db.BeginTx(ctx, nil)
+	userIDs   int64
+	postIDs   int64
+	postTimes []time.Time
+}
 
-	for _, user := range users {
-		if err := store.Users.Create(ctx, tx, user); err != nil {
-			_ = tx.Rollback()
-			log.Println("Error creating user", err)
-			return
-		}
+// Seed inserts generated users, follows, posts and comments with batched
+// COPYs. Users are active, have the user role and share cfg.Password.
+// Everything is seeded in one transaction, a failed seed leaves nothing
+// behind.
+func Seed(ctx context.Context, db *pgxpool.Pool, cfg SeedConfig) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	tx.Commit()
+	if err := seed(ctx, tx, cfg, time.Now().Truncate(time.Second)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
 
-	posts := generatePosts(users)
-	for _, post := range posts {
-		if err := store.Posts.Create(ctx, post); err != nil {
-			log.Println("Error creating post", err)
-			return
-		}
+func seed(ctx context.Context, db seedDB, cfg SeedConfig, now time.Time) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	s := &seeder{
+		db:  db,
+		cfg: cfg,
+		r:   rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+		now: now,
+	}
+
+	steps := []struct {
+		name string
+		fn   func(context.Context) error
+	}{
+		{"users", s.seedUsers},
+		{"followers", s.seedFollowers},
+		{"posts", s.seedPosts},
+		{"comments", s.seedComments},
 	}
 
-	comments := generateComments(posts)
-	for _, comment := range comments {
-		if err := store.Comments.Create(ctx, comment); err != nil {
-			log.Println("Error creating post", err)
-			return
+	for _, step := range steps {
+		start := time.Now()
+		if err := step.fn(ctx); err != nil {
+			return fmt.Errorf("seeding %s: %w", step.name, err)
 		}
+		log.Printf("seeded %s in %s", step.name, time.Since(start).Round(time.Millisecond))
 	}
 
-	log.Println("Seeding has been completed!!!!")
+	return nil
 }
 
-func generateComments(posts []*store.Post) []*store.Comment {
-	comments := make([]*store.Comment, numberOfSeedData)
+func (s *seeder) seedUsers(ctx context.Context) error {
+	// users are copied with explicit ids, so follows and posts can refer to them.
+	base, err := s.maxID(ctx, "users")
+	if err != nil {
+		return err
+	}
+	s.userIDs = base
 
-	for i := range comments {
-		comments[i] = &store.Comment{
-			UserID:  posts[i].UserID,
-			PostID:  posts[i].ID,
-			Content: contents[i],
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.cfg.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	columns := []string{"id", "username", "email", "password", "role_id", "is_active", "created_at"}
+	err = s.copyRows(ctx, "users", columns, s.cfg.Users, func(i int) []any {
+		id := base + int64(i) + 1
+		username := fmt.Sprintf("%s_%s_%d", pick(s.r, adjectives), pick(s.r, animals), id)
+		createdAt := s.now.Add(-s.cfg.Span - s.duration(time.Hour*24*30))
+		return []any{id, username, username + "@example.com", hash, 1, true, createdAt}
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.resetSequence(ctx, "users")
+}
+
+// seedFollowers builds a power law graph: how many users someone follows
+// is spread around the average, who they follow is drawn from a Zipf
+// distribution over a random popularity ranking.
+func (s *seeder) seedFollowers(ctx context.Context) error {
+	n := s.cfg.Users
+	popularity := s.r.Perm(n)
+	zipf := rand.NewZipf(s.r, s.cfg.Skew, 1, uint64(n-1))
+
+	type follow struct{ userID, followerID int64 }
+	var follows []follow
+
+	for i := range n {
+		count := min(int(s.r.ExpFloat64()*float64(s.cfg.Follows)), n-1)
+		followed := make([]int, 0, count)
+
+		// popular users are drawn again and again, give up on the rest
+		// rather than looping.
+		for attempt := 0; len(followed) < count && attempt < count*10; attempt++ {
+			j := popularity[zipf.Uint64()]
+			if j != i && !slices.Contains(followed, j) {
+				followed = append(followed, j)
+			}
+		}
+
+		for _, j := range followed {
+			follows = append(follows, follow{userID: s.userID(j), followerID: s.userID(i)})
 		}
 	}
-	return comments
+
+	columns := []string{"user_id", "follower_id", "create_at"}
+	return s.copyRows(ctx, "followers", columns, len(follows), func(i int) []any {
+		return []any{follows[i].userID, follows[i].followerID, s.now.Add(-s.duration(s.cfg.Span))}
+	})
 }
 
-func generatePosts(users []*store.User) []*store.Post {
-	posts := make([]*store.Post, numberOfSeedData)
-	for i := range posts {
-		user := users[i]
-		posts[i] = &store.Post{
-			UserID:  user.ID,
-			Title:   titles[i],
-			Content: contents[i],
-			Tags:    []string{tags[i]},
+// seedPosts gives most posts to a few very active users.
+func (s *seeder) seedPosts(ctx context.Context) error {
+	base, err := s.maxID(ctx, "posts")
+	if err != nil {
+		return err
+	}
+	s.postIDs = base
+
+	activity := s.r.Perm(s.cfg.Users)
+	zipf := rand.NewZipf(s.r, s.cfg.Skew, 1, uint64(s.cfg.Users-1))
+	tagZipf := rand.NewZipf(s.r, s.cfg.Skew, 1, uint64(len(tags)-1))
+
+	s.postTimes = make([]time.Time, s.cfg.Posts)
+
+	columns := []string{"id", "title", "user_id", "content", "tags", "created_at", "updated_at"}
+	err = s.copyRows(ctx, "posts", columns, s.cfg.Posts, func(i int) []any {
+		postTags := make([]string, 0, 3)
+		for range 1 + s.r.IntN(3) {
+			tag := tags[tagZipf.Uint64()]
+			if !slices.Contains(postTags, tag) {
+				postTags = append(postTags, tag)
+			}
+		}
+
+		createdAt := s.now.Add(-s.duration(s.cfg.Span))
+		s.postTimes[i] = createdAt
+
+		return []any{
+			base + int64(i) + 1,
+			s.sentence(3, 8),
+			s.userID(activity[zipf.Uint64()]),
+			s.sentence(10, 60),
+			postTags,
+			createdAt,
+			createdAt,
 		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.resetSequence(ctx, "posts")
+}
+
+// seedComments puts most comments on a few posts, each after its post.
+func (s *seeder) seedComments(ctx context.Context) error {
+	if s.cfg.Posts == 0 {
+		return nil
 	}
-	return posts
+
+	zipf := rand.NewZipf(s.r, s.cfg.Skew, 1, uint64(s.cfg.Posts-1))
+
+	columns := []string{"post_id", "user_id", "content", "created_at"}
+	return s.copyRows(ctx, "comments", columns, s.cfg.Comments, func(i int) []any {
+		post := int(zipf.Uint64())
+		postedAt := s.postTimes[post]
+
+		return []any{
+			s.postIDs + int64(post) + 1,
+			s.userID(s.r.IntN(s.cfg.Users)),
+			s.sentence(3, 20),
+			postedAt.Add(s.duration(s.now.Sub(postedAt))),
+		}
+	})
 }
 
-func generateUsers() []*store.User {
-	users := make([]*store.User, numberOfSeedData)
+// copyRows COPYs n rows built by row into table, one COPY per batch.
+func (s *seeder) copyRows(ctx context.Context, table string, columns []string, n int, row func(int) []any) error {
+	for start := 0; start < n; start += s.cfg.BatchSize {
+		end := min(start+s.cfg.BatchSize, n)
 
-	for i := range users {
-		users[i] = &store.User{
-			Username: usernames[i],
-			Email:    usernames[i] + "@example.com",
-			RoleID:   1,
+		rows := make([][]any, 0, end-start)
+		for i := start; i < end; i++ {
+			rows = append(rows, row(i))
 		}
+
+		// CopyFrom streams the batch in the binary COPY format.
+		_, err := s.db.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows))
+		if err != nil {
+			return err
+		}
+
+		log.Printf("%s: %d/%d", table, end, n)
 	}
-	return users
+
+	return nil
+}
+
+func (s *seeder) maxID(ctx context.Context, table string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) FROM `+table).Scan(&id)
+	return id, err
+}
+
+// resetSequence moves the id sequence of table past the copied ids.
+func (s *seeder) resetSequence(ctx context.Context, table string) error {
+	_, err := s.db.Exec(ctx, `SELECT setval(pg_get_serial_sequence($1, 'id'), MAX(id)) FROM `+table, table)
+	return err
+}
+
+func (s *seeder) userID(i int) int64 {
+	return s.userIDs + int64(i) + 1
+}
+
+// duration returns a random duration in [0, d).
+func (s *seeder) duration(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(s.r.Int64N(int64(d))).Truncate(time.Second)
+}
+
+func (s *seeder) sentence(minWords, maxWords int) string {
+	n := minWords + s.r.IntN(maxWords-minWords+1)
+
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = pick(s.r, words)
+	}
+	return strings.Join(parts, " ")
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.IntN(len(values))]
 }
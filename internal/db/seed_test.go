@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// recordingDB keeps the copied rows instead of sending them to Postgres,
+// every table starts out empty.
+type recordingDB struct {
+	rows map[string][][]any
+}
+
+func (d *recordingDB) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, rows pgx.CopyFromSource) (int64, error) {
+	var n int64
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return n, err
+		}
+		d.rows[table.Sanitize()] = append(d.rows[table.Sanitize()], values)
+		n++
+	}
+	return n, rows.Err()
+}
+
+func (d *recordingDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return zeroRow{}
+}
+
+func (d *recordingDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+
+type zeroRow struct{}
+
+func (zeroRow) Scan(dest ...any) error {
+	*dest[0].(*int64) = 0
+	return nil
+}
+
+func TestSeed(t *testing.T) {
+	cfg := SeedConfig{
+		Users:     50,
+		Posts:     200,
+		Comments:  500,
+		Follows:   5,
+		Skew:      1.5,
+		Span:      time.Hour * 24 * 30,
+		Seed:      7,
+		BatchSize: 64,
+		Password:  "password",
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	run := func(t *testing.T, cfg SeedConfig) map[string][][]any {
+		t.Helper()
+		db := &recordingDB{rows: make(map[string][][]any)}
+		if err := seed(context.Background(), db, cfg, now); err != nil {
+			t.Fatal(err)
+		}
+
+		// bcrypt salts every hash, the password is the only value that
+		// differs between runs.
+		for _, row := range db.rows[`"users"`] {
+			row[3] = nil
+		}
+		return db.rows
+	}
+
+	t.Run("Should generate the same rows from the same seed", func(t *testing.T) {
+		first, second := run(t, cfg), run(t, cfg)
+
+		for _, table := range []string{`"users"`, `"followers"`, `"posts"`, `"comments"`} {
+			if len(first[table]) == 0 {
+				t.Errorf("Expected rows in %s", table)
+			}
+		}
+		if len(first[`"posts"`]) != cfg.Posts || len(first[`"comments"`]) != cfg.Comments {
+			t.Errorf("Expected %d posts and %d comments, but got %d and %d",
+				cfg.Posts, cfg.Comments, len(first[`"posts"`]), len(first[`"comments"`]))
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Error("Expected the same rows from the same seed")
+		}
+	})
+
+	t.Run("Should generate other rows from another seed", func(t *testing.T) {
+		other := cfg
+		other.Seed++
+
+		if reflect.DeepEqual(run(t, cfg), run(t, other)) {
+			t.Error("Expected other rows from another seed")
+		}
+	})
+
+	t.Run("Should reject negative counts", func(t *testing.T) {
+		for _, negative := range []func(*SeedConfig){
+			func(cfg *SeedConfig) { cfg.Posts = -1 },
+			func(cfg *SeedConfig) { cfg.Comments = -1 },
+			func(cfg *SeedConfig) { cfg.Follows = -1 },
+		} {
+			invalid := cfg
+			negative(&invalid)
+
+			db := &recordingDB{rows: make(map[string][][]any)}
+			if err := seed(context.Background(), db, invalid, now); err == nil {
+				t.Errorf("Expected an error for %+v", invalid)
+			}
+			if len(db.rows) != 0 {
+				t.Errorf("Expected nothing to be seeded, but got %d tables", len(db.rows))
+			}
+		}
+	})
+}
@@ -0,0 +1,151 @@
+// Package events fans out domain events to the connected stream clients.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/metrics"
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	PostCreated    Type = "post.created"
+	CommentCreated Type = "comment.created"
+	Follow         Type = "user.followed"
+	Unfollow       Type = "user.unfollowed"
+	// UserSuspended ends the streams of its recipient, it is not sent to
+	// clients or webhooks.
+	UserSuspended Type = "user.suspended"
+)
+
+// Event is published after a change was committed. It may be received by
+// its actor, the listed recipients and, if FollowersOf is set, everyone
+// following that user.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        Type            `json:"type"`
+	ActorID     int64           `json:"actor_id"`
+	Recipients  []int64         `json:"recipients,omitempty"`
+	FollowersOf int64           `json:"followers_of,omitempty"`
+	Data        json.RawMessage `json:"data"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func New(t Type, actorID int64, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:        uuid.NewString(),
+		Type:      t,
+		ActorID:   actorID,
+		Data:      raw,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+type Broker interface {
+	Publish(context.Context, Event) error
+	Subscribe() *Subscription
+	// Run delivers events until ctx is done, then closes all subscriptions.
+	Run(ctx context.Context) error
+}
+
+// subscriptionBuffer is how many events a slow client may lag behind
+// before events are dropped for it.
+const subscriptionBuffer = 64
+
+// Hub delivers events to the subscribers of this process.
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+func (h *Hub) Publish(ctx context.Context, event Event) error {
+	h.broadcast(event)
+	return nil
+}
+
+func (h *Hub) Subscribe() *Subscription {
+	sub := &Subscription{hub: h, ch: make(chan Event, subscriptionBuffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(sub.ch)
+		sub.once.Do(func() {})
+		return sub
+	}
+
+	h.subs[sub] = struct{}{}
+	metrics.StreamSubscribers.Inc()
+	return sub
+}
+
+func (h *Hub) Run(ctx context.Context) error {
+	<-ctx.Done()
+	h.close()
+	return nil
+}
+
+func (h *Hub) close() {
+	h.mu.Lock()
+	h.closed = true
+	subs := h.subs
+	h.subs = make(map[*Subscription]struct{})
+	h.mu.Unlock()
+
+	for sub := range subs {
+		sub.once.Do(func() {
+			close(sub.ch)
+			metrics.StreamSubscribers.Dec()
+		})
+	}
+}
+
+func (h *Hub) broadcast(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			metrics.EventsDropped.Inc()
+		}
+	}
+}
+
+type Subscription struct {
+	hub  *Hub
+	ch   chan Event
+	once sync.Once
+}
+
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subs, s)
+		close(s.ch)
+		s.hub.mu.Unlock()
+
+		metrics.StreamSubscribers.Dec()
+	})
+}
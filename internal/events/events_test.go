@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+
+	t.Run("Should deliver events to every subscriber", func(t *testing.T) {
+		first, second := hub.Subscribe(), hub.Subscribe()
+		defer first.Close()
+		defer second.Close()
+
+		event, err := New(PostCreated, 42, map[string]int{"id": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := hub.Publish(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, sub := range []*Subscription{first, second} {
+			if got := <-sub.Events(); got.ID != event.ID {
+				t.Errorf("Expected event %s, but got %s", event.ID, got.ID)
+			}
+		}
+	})
+
+	t.Run("Should close subscriptions when stopped", func(t *testing.T) {
+		sub := hub.Subscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		hub.Run(ctx)
+
+		if _, ok := <-sub.Events(); ok {
+			t.Error("Expected the subscription to be closed")
+		}
+
+		sub.Close()
+	})
+}
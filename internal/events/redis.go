@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisBroker publishes events on a Redis channel, so the subscribers of
+// every API instance receive them.
+type RedisBroker struct {
+	hub     *Hub
+	rdb     *redis.Client
+	channel string
+	logger  *slog.Logger
+}
+
+func NewRedisBroker(rdb *redis.Client, channel string, logger *slog.Logger) *RedisBroker {
+	return &RedisBroker{
+		hub:     NewHub(),
+		rdb:     rdb,
+		channel: channel,
+		logger:  logger,
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.rdb.Publish(ctx, b.channel, data).Err()
+}
+
+func (b *RedisBroker) Subscribe() *Subscription {
+	return b.hub.Subscribe()
+}
+
+// Run forwards the events of the Redis channel to the local subscribers
+// until ctx is done.
+func (b *RedisBroker) Run(ctx context.Context) error {
+	defer b.hub.close()
+
+	// The channel of go-redis reconnects on its own if the connection drops.
+	pubsub := b.rdb.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				b.logger.Error("error decoding event", "error", err.Error())
+				continue
+			}
+			b.hub.broadcast(event)
+		}
+	}
+}
@@ -7,6 +7,8 @@ const (
 	FromName            = "GopherSocial"
 	maxRetries          = 3
 	UserWelcomeTemplate = "user_invitation.html"
+	EmailChangeTemplate = "email_change_confirmation.html"
+	EmailNoticeTemplate = "email_change_notice.html"
 )
 
 //go:embed "templates"
@@ -4,9 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/MohummedSoliman/social/internal/metrics"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
@@ -15,14 +16,16 @@ type SendGridMailer struct {
 	fromEmail string
 	apiKey    string
 	client    *sendgrid.Client
+	logger    *slog.Logger
 }
 
-func NewSendgrid(apiKey, fromEmail string) *SendGridMailer {
+func NewSendgrid(apiKey, fromEmail string, logger *slog.Logger) *SendGridMailer {
 	client := sendgrid.NewSendClient(apiKey)
 	return &SendGridMailer{
 		fromEmail: fromEmail,
 		apiKey:    apiKey,
 		client:    client,
+		logger:    logger,
 	}
 }
 
@@ -57,13 +60,20 @@ func (m *SendGridMailer) Send(templateFile, username, email string, data any, is
 	for i := range maxRetries {
 		response, err := m.client.Send(message)
 		if err != nil {
-			log.Printf("Failed to send mail to %v, attempt %d of %d", email, i+1, maxRetries)
-			log.Printf("Error: %v", err.Error())
+			m.logger.Warn("failed to send mail",
+				"template", templateFile,
+				"attempt", i+1,
+				"max_retries", maxRetries,
+				"error", err.Error(),
+			)
 			time.Sleep(time.Second * time.Duration(i+1))
 			continue
 		}
-		log.Printf("Email sent with status %v", response.StatusCode)
+		m.logger.Info("mail sent", "template", templateFile, "status", response.StatusCode)
+		metrics.MailsSent.WithLabelValues(templateFile, "sent").Inc()
 		return nil
 	}
+
+	metrics.MailsSent.WithLabelValues(templateFile, "failed").Inc()
 	return fmt.Errorf("failed to send email after %d attempts", maxRetries)
 }
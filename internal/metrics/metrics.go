@@ -0,0 +1,180 @@
+// Package metrics defines the prometheus collectors exposed by the API.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "social"
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by method, chi route pattern and status.",
+		},
+		[]string{"method", "route", "status"},
+	)
+
+	HTTPDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by method and chi route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"method", "route"},
+	)
+
+	CacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Number of cache lookups by cache and result (hit, miss, negative, error, bypass).",
+		},
+		[]string{"cache", "result"},
+	)
+
+	DBReads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "db_reads_total",
+			Help:      "Number of read only queries routable to replicas by the pool they ran on (primary, replica).",
+		},
+		[]string{"target"},
+	)
+
+	DBReplicaHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "db_replica_healthy",
+			Help:      "Whether a read replica is in rotation.",
+		},
+		[]string{"replica"},
+	)
+
+	CacheBreakerState = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_breaker_state",
+			Help:      "State of the Redis circuit breaker (0 closed, 1 open, 2 half open).",
+		},
+	)
+
+	CacheBreakerTrips = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_breaker_trips_total",
+			Help:      "Number of times the Redis circuit breaker opened.",
+		},
+	)
+
+	CacheBroadcastFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_broadcast_failures_total",
+			Help:      "Number of cache invalidations other instances could not be notified of.",
+		},
+	)
+
+	LocalCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "local_cache_requests_total",
+			Help:      "Number of in-process cache lookups by result (hit, miss).",
+		},
+		[]string{"result"},
+	)
+
+	LocalCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "local_cache_entries",
+			Help:      "Number of entries in the in-process cache.",
+		},
+	)
+
+	LocalCacheEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "local_cache_evictions_total",
+			Help:      "Number of entries evicted from the full in-process cache.",
+		},
+	)
+
+	RateLimiterRejections = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ratelimiter_rejections_total",
+			Help:      "Number of requests rejected by the rate limiter.",
+		},
+	)
+
+	MailsSent = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mailer_sends_total",
+			Help:      "Number of mails by template and outcome (sent, failed).",
+		},
+		[]string{"template", "outcome"},
+	)
+
+	StreamSubscribers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stream_subscribers",
+			Help:      "Number of clients connected to the event stream.",
+		},
+	)
+
+	EventsDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_dropped_total",
+			Help:      "Number of events dropped for stream clients that fell behind.",
+		},
+	)
+
+	WebhookDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_deliveries_total",
+			Help:      "Number of webhook delivery attempts by outcome (succeeded, retried, failed).",
+		},
+		[]string{"outcome"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		DBReads,
+		DBReplicaHealthy,
+		CacheRequests,
+		CacheBreakerState,
+		CacheBreakerTrips,
+		CacheBroadcastFailures,
+		LocalCacheRequests,
+		LocalCacheEntries,
+		LocalCacheEvictions,
+		RateLimiterRejections,
+		MailsSent,
+		StreamSubscribers,
+		EventsDropped,
+		WebhookDeliveries,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
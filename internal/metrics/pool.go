@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector exposes the stats of a pgx pool. The database/sql handles
+// the stores use borrow their connections from it, so it is where the
+// connections are actually counted and waited for.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	constructingConns *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquires          *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+	emptyAcquireWait  *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	newConns          *prometheus.Desc
+	lifetimeDestroys  *prometheus.Desc
+	idleDestroys      *prometheus.Desc
+}
+
+// RegisterPool exposes the connection pool stats of pool under the given
+// name.
+func RegisterPool(pool *pgxpool.Pool, name string) error {
+	labels := prometheus.Labels{"db_name": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", metric), help, nil, labels)
+	}
+
+	return Registry.Register(&poolCollector{
+		pool:              pool,
+		acquiredConns:     desc("acquired_conns", "Number of connections currently in use."),
+		idleConns:         desc("idle_conns", "Number of idle connections."),
+		constructingConns: desc("constructing_conns", "Number of connections being established."),
+		totalConns:        desc("total_conns", "Number of open connections, acquired, idle and constructing."),
+		maxConns:          desc("max_conns", "Maximum size of the pool."),
+		acquires:          desc("acquires_total", "Number of successful connection acquires."),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Time spent acquiring connections."),
+		emptyAcquires:     desc("empty_acquires_total", "Number of acquires that had to wait for a connection."),
+		emptyAcquireWait:  desc("empty_acquire_wait_seconds_total", "Time spent waiting for a connection while the pool was empty."),
+		canceledAcquires:  desc("canceled_acquires_total", "Number of acquires cancelled by their context."),
+		newConns:          desc("new_conns_total", "Number of connections opened."),
+		lifetimeDestroys:  desc("max_lifetime_destroys_total", "Number of connections closed for exceeding their maximum lifetime."),
+		idleDestroys:      desc("max_idle_destroys_total", "Number of connections closed for exceeding the maximum idle time."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	gauge := func(desc *prometheus.Desc, v int32) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(v))
+	}
+	counter := func(desc *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v)
+	}
+
+	gauge(c.acquiredConns, stat.AcquiredConns())
+	gauge(c.idleConns, stat.IdleConns())
+	gauge(c.constructingConns, stat.ConstructingConns())
+	gauge(c.totalConns, stat.TotalConns())
+	gauge(c.maxConns, stat.MaxConns())
+	counter(c.acquires, float64(stat.AcquireCount()))
+	counter(c.acquireDuration, stat.AcquireDuration().Seconds())
+	counter(c.emptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(c.emptyAcquireWait, stat.EmptyAcquireWaitTime().Seconds())
+	counter(c.canceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(c.newConns, float64(stat.NewConnsCount()))
+	counter(c.lifetimeDestroys, float64(stat.MaxLifetimeDestroyCount()))
+	counter(c.idleDestroys, float64(stat.MaxIdleDestroyCount()))
+}
@@ -0,0 +1,380 @@
+// Package migrate applies versioned SQL migrations to Postgres. It keeps
+// its state in the schema_migrations table of golang-migrate, so databases
+// migrated with that tool can switch to it.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// lockID keys the advisory lock held while migrating, so replicas starting
+// together apply every migration once.
+const lockID = 7_046_231_918
+
+var (
+	ErrDirty          = errors.New("database is dirty, fix it and use force")
+	ErrUnknownVersion = errors.New("unknown migration version")
+	ErrNoDown         = errors.New("migration has no down file")
+)
+
+var fileRe = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+
+	up, down string
+}
+
+type Status struct {
+	Migration
+	Applied bool
+}
+
+type Migrator struct {
+	db         *sql.DB
+	fsys       fs.FS
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// New loads the migrations in the root of fsys.
+func New(db *sql.DB, fsys fs.FS, logger *slog.Logger) (*Migrator, error) {
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, fsys: fsys, migrations: migrations, logger: logger}, nil
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := fileRe.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.up = e.Name()
+		} else {
+			m.down = e.Name()
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, current int64) error {
+		return m.migrate(ctx, conn, current, m.latest())
+	})
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn, current int64) error {
+		i, err := m.index(current)
+		if err != nil {
+			return err
+		}
+
+		var target int64
+		if i-n >= 0 {
+			target = m.migrations[i-n].Version
+		}
+		return m.migrate(ctx, conn, current, target)
+	})
+}
+
+// Goto migrates up or down to version, 0 reverts every migration.
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if _, err := m.index(version); err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn, current int64) error {
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Force sets the version without running migrations and clears the dirty
+// flag, after a failed migration was repaired by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if _, err := m.index(version); err != nil {
+		return err
+	}
+
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(conn)
+
+	return setVersion(ctx, conn, version)
+}
+
+// Status returns the current version, whether it is dirty and every
+// migration with whether it was applied.
+func (m *Migrator) Status(ctx context.Context) (int64, bool, []Status, error) {
+	if err := ensureTable(ctx, m.db); err != nil {
+		return 0, false, nil, err
+	}
+
+	version, dirty, err := getVersion(ctx, m.db)
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		statuses = append(statuses, Status{Migration: mig, Applied: mig.Version <= version})
+	}
+	return version, dirty, statuses, nil
+}
+
+func (m *Migrator) latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// index returns the position of version, -1 for no version at all.
+func (m *Migrator) index(version int64) (int, error) {
+	if version == 0 {
+		return -1, nil
+	}
+
+	i := slices.IndexFunc(m.migrations, func(mig Migration) bool {
+		return mig.Version == version
+	})
+	if i < 0 {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return i, nil
+}
+
+// migrate runs the migrations between current and target, one transaction
+// per migration, so a failure leaves the last one that succeeded applied.
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, current, target int64) error {
+	for _, mig := range m.migrations {
+		if mig.Version > current && mig.Version <= target {
+			if err := m.apply(ctx, conn, mig.up, mig.Version); err != nil {
+				return err
+			}
+			m.logger.Info("migration applied", "version", mig.Version, "name", mig.Name)
+		}
+	}
+
+	for i, mig := range slices.Backward(m.migrations) {
+		if mig.Version <= current && mig.Version > target {
+			if mig.down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDown, mig.Version, mig.Name)
+			}
+
+			var previous int64
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := m.apply(ctx, conn, mig.down, previous); err != nil {
+				return err
+			}
+			m.logger.Info("migration reverted", "version", mig.Version, "name", mig.Name)
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, file string, version int64) error {
+	query, err := fs.ReadFile(m.fsys, file)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(query)); err != nil {
+		return fmt.Errorf("migration %s: %w", file, err)
+	}
+
+	if err := setVersion(ctx, tx, version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withLock runs fn holding the migration lock, with the current version
+// of a database that is not dirty.
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn, int64) error) error {
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(conn)
+
+	version, dirty, err := getVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w: version %d", ErrDirty, version)
+	}
+
+	return fn(conn, version)
+}
+
+// lock takes the advisory lock on a connection of its own, it is released
+// when that connection ends even if the process dies.
+func (m *Migrator) lock(ctx context.Context) (*sql.Conn, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ensureTable(ctx, conn); err != nil {
+		m.unlock(conn)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (m *Migrator) unlock(conn *sql.Conn) {
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+		m.logger.Error("error releasing migration lock", "error", err.Error())
+	}
+	conn.Close()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func ensureTable(ctx context.Context, db execer) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT NOT NULL PRIMARY KEY,
+		dirty BOOLEAN NOT NULL
+	)`)
+	return err
+}
+
+// getVersion returns the applied version, 0 when nothing was applied.
+func getVersion(ctx context.Context, db execer) (int64, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	}
+
+	// golang-migrate stores -1 for no version.
+	return max(version, 0), dirty, nil
+}
+
+func setVersion(ctx context.Context, db execer, version int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)`, version)
+	return err
+}
+
+// Create adds empty up and down files for the next version to dir and
+// returns their paths.
+func Create(dir, name string) ([]string, error) {
+	name = strings.Join(strings.Fields(strings.ToLower(name)), "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+
+	migrations, err := load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", version, name, direction))
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return paths, err
+		}
+		f.Close()
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/MohummedSoliman/social/cmd/migrate/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Should order migrations by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"000010_b.up.sql":   {},
+			"000010_b.down.sql": {},
+			"000002_a.up.sql":   {},
+			"README.md":         {},
+		}
+
+		got, err := load(fsys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].Version != 2 || got[1].Version != 10 || got[1].down == "" {
+			t.Errorf("Expected versions 2 and 10, but got %+v", got)
+		}
+	})
+
+	t.Run("Should reject a version used twice", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"000001_a.up.sql": {},
+			"000001_b.up.sql": {},
+		}
+
+		if _, err := load(fsys); err == nil {
+			t.Error("Expected an error for the duplicate version")
+		}
+	})
+
+	t.Run("Should load the embedded migrations", func(t *testing.T) {
+		got, err := load(migrations.FS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range got {
+			if m.Version != int64(i+1) || m.down == "" {
+				t.Errorf("Expected migration %d with a down file, but got %+v", i+1, m)
+			}
+		}
+	})
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "000007_init.up.sql"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := Create(dir, "Add Likes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		filepath.Join(dir, "000008_add_likes.up.sql"),
+		filepath.Join(dir, "000008_add_likes.down.sql"),
+	}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("Expected %v, but got %v", want, paths)
+	}
+}
@@ -0,0 +1,274 @@
+// Package openapi builds OpenAPI 3 documents from route descriptions and Go
+// types, turning validator tags into schema constraints.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem maps lower case HTTP methods to their operation.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Route describes one operation. Query and Body are struct values whose
+// json and validate tags describe the query parameters and request body,
+// Response is the value wrapped in the {"data": ...} envelope. Headers lists
+// optional request headers the operation understands, RequiredHeaders the
+// ones it rejects requests without.
+type Route struct {
+	Method          string
+	Path            string
+	Summary         string
+	Tag             string
+	Security        string
+	Headers         []string
+	RequiredHeaders []string
+	Query           any
+	Body            any
+	Status          int
+	Response        any
+	ContentType     string
+}
+
+type Builder struct {
+	doc          Document
+	errorContent map[string]MediaType
+}
+
+func New(title, version string) *Builder {
+	return &Builder{
+		doc: Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version},
+			Paths:   make(map[string]PathItem),
+			Components: Components{
+				Schemas:         make(map[string]*Schema),
+				SecuritySchemes: make(map[string]SecurityScheme),
+			},
+		},
+	}
+}
+
+func (b *Builder) AddSecurityScheme(name string, scheme SecurityScheme) {
+	b.doc.Components.SecuritySchemes[name] = scheme
+}
+
+// SetErrorResponse documents the body every operation returns on failure.
+func (b *Builder) SetErrorResponse(contentType string, schema *Schema) {
+	b.errorContent = map[string]MediaType{contentType: {Schema: schema}}
+}
+
+// AddSchema registers a named schema that routes can reference.
+func (b *Builder) AddSchema(name string, schema *Schema) {
+	b.doc.Components.Schemas[name] = schema
+}
+
+func (b *Builder) Add(route Route) {
+	op := &Operation{
+		Summary:   route.Summary,
+		Responses: make(map[string]Response),
+	}
+
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	if route.Security != "" {
+		op.Security = []map[string][]string{{route.Security: {}}}
+	}
+
+	for _, name := range pathParams(route.Path) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	for _, name := range route.Headers {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:   name,
+			In:     "header",
+			Schema: &Schema{Type: "string"},
+		})
+	}
+	for _, name := range route.RequiredHeaders {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "header",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, b.queryParams(reflect.TypeOf(route.Query))...)
+	}
+
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"application/json": {Schema: b.SchemaFor(reflect.TypeOf(route.Body))},
+			},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	resp := Response{Description: http.StatusText(status)}
+	switch {
+	case route.ContentType != "":
+		resp.Content = map[string]MediaType{route.ContentType: {}}
+	case route.Response != nil:
+		resp.Content = map[string]MediaType{
+			"application/json": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"data": b.SchemaFor(reflect.TypeOf(route.Response))},
+			}},
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+
+	if b.errorContent != nil {
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     b.errorContent,
+		}
+	}
+
+	item, ok := b.doc.Paths[route.Path]
+	if !ok {
+		item = make(PathItem)
+		b.doc.Paths[route.Path] = item
+	}
+	item[strings.ToLower(route.Method)] = op
+}
+
+func (b *Builder) Document() Document {
+	return b.doc
+}
+
+// Operations returns every documented operation as "METHOD /path".
+func (d Document) Operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for method := range item {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func pathParams(path string) []string {
+	var params []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			params = append(params, strings.Trim(segment, "{}"))
+		}
+	}
+	return params
+}
+
+func (b *Builder) queryParams(t reflect.Type) []Parameter {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var params []Parameter
+	for _, field := range fields(t) {
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		schema := b.SchemaFor(field.Type)
+		required := applyValidation(schema, field.Tag.Get("validate"))
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "query",
+			Required: required,
+			Schema:   schema,
+		})
+	}
+	return params
+}
@@ -0,0 +1,190 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaFor returns the schema of t, named structs are registered as
+// components and referenced.
+func (b *Builder) SchemaFor(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time", Nullable: nullable}
+	case t == rawMessageType:
+		return &Schema{Nullable: true}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string", Nullable: nullable}
+	case reflect.Bool:
+		return &Schema{Type: "boolean", Nullable: nullable}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Nullable: nullable}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Nullable: nullable}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: nullable}
+		}
+		return &Schema{Type: "array", Items: b.SchemaFor(t.Elem()), Nullable: true}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.SchemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+
+		name := t.Name()
+		if _, ok := b.doc.Components.Schemas[name]; !ok {
+			// register first, so recursive types terminate.
+			b.doc.Components.Schemas[name] = &Schema{}
+			*b.doc.Components.Schemas[name] = *b.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (b *Builder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for _, field := range fields(t) {
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		prop := b.SchemaFor(field.Type)
+		if prop.Ref != "" {
+			schema.Properties[name] = prop
+			continue
+		}
+
+		if applyValidation(prop, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+
+	return schema
+}
+
+// fields returns the exported fields of t, flattening embedded structs the
+// same way encoding/json does.
+func fields(t reflect.Type) []reflect.StructField {
+	var out []reflect.StructField
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.Anonymous {
+			ft := field.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+				out = append(out, fields(ft)...)
+				continue
+			}
+		}
+
+		if field.IsExported() {
+			out = append(out, field)
+		}
+	}
+	return out
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// applyValidation copies the constraints of a validator tag into schema and
+// reports whether the field is required.
+func applyValidation(schema *Schema, tag string) bool {
+	required := false
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "dive":
+			// the remaining rules apply to the elements.
+			return required
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "gte":
+			setBound(schema, param, true)
+		case "max", "lte":
+			setBound(schema, param, false)
+		case "len":
+			setBound(schema, param, true)
+			setBound(schema, param, false)
+		}
+	}
+
+	return required
+}
+
+func setBound(schema *Schema, param string, lower bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	i := int(n)
+
+	switch schema.Type {
+	case "string":
+		if lower {
+			schema.MinLength = &i
+		} else {
+			schema.MaxLength = &i
+		}
+	case "array":
+		if lower {
+			schema.MinItems = &i
+		} else {
+			schema.MaxItems = &i
+		}
+	case "integer", "number":
+		if lower {
+			schema.Minimum = &n
+		} else {
+			schema.Maximum = &n
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    int64           `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+type AuditLogQuery struct {
+	Limit      int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset     int    `json:"offset" validate:"gte=0"`
+	ActorID    int64  `json:"actor_id" validate:"gte=0"`
+	TargetType string `json:"target_type" validate:"max=50"`
+	TargetID   int64  `json:"target_id" validate:"gte=0"`
+	Action     string `json:"action" validate:"max=100"`
+	Since      string `json:"since"`
+	Until      string `json:"until"`
+}
+
+func (q AuditLogQuery) Parse(r *http.Request) (AuditLogQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = o
+	}
+
+	actorID := qs.Get("actor_id")
+	if actorID != "" {
+		id, err := strconv.ParseInt(actorID, 10, 64)
+		if err != nil {
+			return q, err
+		}
+		q.ActorID = id
+	}
+
+	targetID := qs.Get("target_id")
+	if targetID != "" {
+		id, err := strconv.ParseInt(targetID, 10, 64)
+		if err != nil {
+			return q, err
+		}
+		q.TargetID = id
+	}
+
+	if targetType := qs.Get("target_type"); targetType != "" {
+		q.TargetType = targetType
+	}
+
+	if action := qs.Get("action"); action != "" {
+		q.Action = action
+	}
+
+	if since := qs.Get("since"); since != "" {
+		q.Since = parseTime(since)
+	}
+
+	if until := qs.Get("until"); until != "" {
+		q.Until = parseTime(until)
+	}
+
+	return q, nil
+}
+
+type AuditLogStore struct {
+	db *sql.DB
+}
+
+func (s *AuditLogStore) Create(ctx context.Context, entry *AuditEntry) error {
+	query := `INSERT INTO audit_log (actor_id, action, target_type, target_id, ip, request_id, before, after)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return conn(ctx, s.db).QueryRowContext(
+		ctx,
+		query,
+		entry.ActorID,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.IP,
+		entry.RequestID,
+		nullJSON(entry.Before),
+		nullJSON(entry.After),
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (s *AuditLogStore) List(ctx context.Context, q AuditLogQuery) ([]*AuditEntry, error) {
+	query := `SELECT id, actor_id, action, target_type, target_id, ip, request_id, before, after, created_at
+			  FROM audit_log
+			  WHERE ($1 = 0 OR actor_id = $1) AND
+					($2 = '' OR target_type = $2) AND
+					($3 = 0 OR target_id = $3) AND
+					($4 = '' OR action = $4) AND
+					created_at >= COALESCE(NULLIF($5, '')::timestamptz, '-infinity') AND
+					created_at <= COALESCE(NULLIF($6, '')::timestamptz, 'infinity')
+			  ORDER BY created_at DESC, id DESC
+			  LIMIT $7 OFFSET $8`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, q.ActorID, q.TargetType, q.TargetID, q.Action, q.Since, q.Until, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AuditEntry
+	for rows.Next() {
+		var (
+			entry         AuditEntry
+			before, after []byte
+		)
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.IP,
+			&entry.RequestID,
+			&before,
+			&after,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Before = before
+		entry.After = after
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// nullJSON passes empty data as NULL and the rest as text for jsonb columns.
+func nullJSON(data json.RawMessage) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,66 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+// TestAuditLogStore needs a migrated database, set TEST_DB_ADDR to run it.
+func TestAuditLogStore(t *testing.T) {
+	_, db := openPostgres(t)
+
+	s := store.NewStorage(db)
+	ctx := context.Background()
+
+	t.Run("Should store the before and after data as jsonb", func(t *testing.T) {
+		entry := &store.AuditEntry{
+			ActorID:    1,
+			Action:     "user.role.update",
+			TargetType: "user",
+			TargetID:   time.Now().UnixNano(),
+			Before:     json.RawMessage(`{"role_id": 1}`),
+			After:      json.RawMessage(`{"role_id": 2}`),
+		}
+		if err := s.AuditLogs.Create(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := s.AuditLogs.List(ctx, store.AuditLogQuery{Limit: 10, TargetType: "user", TargetID: entry.TargetID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("Expected 1 entry, but got %d", len(entries))
+		}
+
+		got := entries[0]
+		if string(got.Before) != `{"role_id": 1}` || string(got.After) != `{"role_id": 2}` {
+			t.Errorf("Expected the data to round trip, but got before %s and after %s", got.Before, got.After)
+		}
+	})
+
+	t.Run("Should store missing data as null", func(t *testing.T) {
+		entry := &store.AuditEntry{
+			ActorID:    1,
+			Action:     "post.delete",
+			TargetType: "post",
+			TargetID:   time.Now().UnixNano(),
+			Before:     json.RawMessage(`{"title": "title"}`),
+		}
+		if err := s.AuditLogs.Create(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := s.AuditLogs.List(ctx, store.AuditLogQuery{Limit: 10, TargetType: "post", TargetID: entry.TargetID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].After != nil {
+			t.Errorf("Expected one entry without after data, but got %+v", entries)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// backend is the key value store the caches are built on, so every cache
+// can be served from Redis, from memory or from both.
+type backend interface {
+	// Get reports false if key is not cached.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+	SetNX(ctx context.Context, key string, data []byte, ttl time.Duration) (bool, error)
+	Del(ctx context.Context, keys ...string) error
+	// Incr increments the counter at key and (re)sets its ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// System names the backend in spans.
+	System() string
+}
+
+type redisBackend struct {
+	db *redis.Client
+}
+
+func (b *redisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := b.db.Get(ctx, key).Bytes()
+	switch err {
+	case nil:
+		return data, true, nil
+	case redis.Nil:
+		return nil, false, nil
+	default:
+		return nil, false, err
+	}
+}
+
+func (b *redisBackend) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	return b.db.Set(ctx, key, data, ttl).Err()
+}
+
+func (b *redisBackend) SetNX(ctx context.Context, key string, data []byte, ttl time.Duration) (bool, error) {
+	return b.db.SetNX(ctx, key, data, ttl).Result()
+}
+
+func (b *redisBackend) Del(ctx context.Context, keys ...string) error {
+	return b.db.Del(ctx, keys...).Err()
+}
+
+func (b *redisBackend) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := b.db.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (b *redisBackend) System() string {
+	return "redis"
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/metrics"
+)
+
+// ErrUnavailable is returned without calling the backend while its circuit
+// breaker is open.
+var ErrUnavailable = errors.New("cache unavailable")
+
+const (
+	breakerThreshold = 5
+	breakerCooldown  = time.Second * 10
+)
+
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker stops calling a failing backend: after breakerThreshold failures
+// in a row it opens for breakerCooldown, then lets a single call through to
+// probe whether the backend recovered.
+type breaker struct {
+	next backend
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func newBreaker(next backend) *breaker {
+	return &breaker{next: next, now: time.Now}
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerClosed:
+		return true
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		return true
+	default:
+		// A probe is already in flight.
+		return false
+	}
+}
+
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case err == nil:
+		b.failures = 0
+		b.setState(breakerClosed)
+	case b.state == breakerClosed && errors.Is(err, context.Canceled):
+		// The caller gave up, that says nothing about the backend.
+	default:
+		b.failures++
+		if b.state == breakerHalfOpen || b.failures >= breakerThreshold {
+			b.openedAt = b.now()
+			b.setState(breakerOpen)
+		}
+	}
+}
+
+func (b *breaker) setState(state int) {
+	if state == breakerOpen && b.state != breakerOpen {
+		metrics.CacheBreakerTrips.Inc()
+	}
+	b.state = state
+	metrics.CacheBreakerState.Set(float64(state))
+}
+
+func (b *breaker) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if !b.allow() {
+		return nil, false, ErrUnavailable
+	}
+	data, ok, err := b.next.Get(ctx, key)
+	b.record(err)
+	return data, ok, err
+}
+
+func (b *breaker) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if !b.allow() {
+		return ErrUnavailable
+	}
+	err := b.next.Set(ctx, key, data, ttl)
+	b.record(err)
+	return err
+}
+
+func (b *breaker) SetNX(ctx context.Context, key string, data []byte, ttl time.Duration) (bool, error) {
+	if !b.allow() {
+		return false, ErrUnavailable
+	}
+	ok, err := b.next.SetNX(ctx, key, data, ttl)
+	b.record(err)
+	return ok, err
+}
+
+func (b *breaker) Del(ctx context.Context, keys ...string) error {
+	if !b.allow() {
+		return ErrUnavailable
+	}
+	err := b.next.Del(ctx, keys...)
+	b.record(err)
+	return err
+}
+
+func (b *breaker) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if !b.allow() {
+		return 0, ErrUnavailable
+	}
+	n, err := b.next.Incr(ctx, key, ttl)
+	b.record(err)
+	return n, err
+}
+
+func (b *breaker) System() string {
+	return b.next.System()
+}
+
+// errorResult labels a failed cache request in metrics.
+func errorResult(err error) string {
+	if errors.Is(err, ErrUnavailable) {
+		return "bypass"
+	}
+	return "error"
+}
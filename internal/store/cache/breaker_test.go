@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type failingBackend struct {
+	*LRU
+	err   error
+	calls int
+}
+
+func (f *failingBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, false, f.err
+	}
+	return f.LRU.Get(ctx, key)
+}
+
+func (f *failingBackend) Del(ctx context.Context, keys ...string) error {
+	f.calls++
+	if f.err != nil {
+		return f.err
+	}
+	return f.LRU.Del(ctx, keys...)
+}
+
+func (f *failingBackend) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	return f.LRU.Incr(ctx, key, ttl)
+}
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	next := &failingBackend{LRU: NewLRU(10, 0), err: errors.New("connection refused")}
+	b := newBreaker(next)
+	b.now = func() time.Time { return now }
+
+	for range breakerThreshold {
+		b.Get(ctx, "a")
+	}
+
+	t.Run("Should bypass the backend once open", func(t *testing.T) {
+		_, _, err := b.Get(ctx, "a")
+		if !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("Expected ErrUnavailable, got %v", err)
+		}
+		if next.calls != breakerThreshold {
+			t.Errorf("Expected %d backend calls, got %d", breakerThreshold, next.calls)
+		}
+	})
+
+	t.Run("Should close after a successful probe", func(t *testing.T) {
+		next.err = nil
+		now = now.Add(breakerCooldown)
+
+		if _, _, err := b.Get(ctx, "a"); err != nil {
+			t.Fatalf("Expected the probe to reach the backend, got %v", err)
+		}
+		if _, _, err := b.Get(ctx, "a"); err != nil {
+			t.Errorf("Expected the breaker to be closed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/logger"
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+// WithCache serves the reads of s that c caches from the cache, and
+// invalidates the cached entries when s changes them.
+func WithCache(s store.Storage, c Storage) store.Storage {
+	s.Users = &cachedUsers{Users: s.Users, cache: c.Users, posts: c.Posts}
+	s.Posts = &cachedPosts{Posts: s.Posts, cache: c.Posts}
+	s.Comments = &cachedComments{Comments: s.Comments, cache: c.Posts}
+	return s
+}
+
+// invalidatePost drops the cached post once the write is committed, so a
+// reader can not cache it again from before the change. It does not fail
+// the write, the entry expires on its own if it can not be invalidated.
+func invalidatePost(ctx context.Context, c Posts, postID int64) {
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		dropPost(ctx, c, postID)
+	})
+}
+
+func dropPost(ctx context.Context, c Posts, postID int64) {
+	if err := c.Invalidate(ctx, postID); err != nil {
+		logger.FromContext(ctx).Error("error invalidating cached post", "post_id", postID, "error", err.Error())
+	}
+}
+
+// invalidateUser drops the cached user once the write is committed, like
+// invalidatePost.
+func invalidateUser(ctx context.Context, c Users, userID int64) {
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		if err := c.Invalidate(ctx, userID); err != nil {
+			logger.FromContext(ctx).Error("error invalidating cached user", "user_id", userID, "error", err.Error())
+		}
+	})
+}
+
+// cachedUsers caches GetUserByID, which authenticates every request, and
+// drops the entry on every change of the user. PurgeDeleted only drops the
+// posts it removed or took comments from: soft deleted users are not
+// returned, so not cached.
+type cachedUsers struct {
+	store.Users
+	cache Users
+	posts Posts
+}
+
+// GetUserByID also caches users that do not exist, so requests for made up
+// IDs do not all reach the database.
+func (c *cachedUsers) GetUserByID(ctx context.Context, userID int64) (*store.User, error) {
+	return c.cache.User(ctx, userID, func(ctx context.Context) (*store.User, error) {
+		return c.Users.GetUserByID(ctx, userID)
+	})
+}
+
+func (c *cachedUsers) CreateAndInviate(ctx context.Context, user *store.User, token string, invitationExp time.Duration) error {
+	err := c.Users.CreateAndInviate(ctx, user, token, invitationExp)
+	if err == nil {
+		invalidateUser(ctx, c.cache, user.ID)
+	}
+	return err
+}
+
+// Create drops a missing entry a lookup of the new ID may have cached.
+func (c *cachedUsers) Create(ctx context.Context, user *store.User) error {
+	err := c.Users.Create(ctx, user)
+	if err == nil {
+		invalidateUser(ctx, c.cache, user.ID)
+	}
+	return err
+}
+
+func (c *cachedUsers) ActivateUser(ctx context.Context, token string) (*store.User, error) {
+	user, err := c.Users.ActivateUser(ctx, token)
+	if err == nil {
+		invalidateUser(ctx, c.cache, user.ID)
+	}
+	return user, err
+}
+
+func (c *cachedUsers) Delete(ctx context.Context, userID int64) error {
+	err := c.Users.Delete(ctx, userID)
+	if err == nil {
+		invalidateUser(ctx, c.cache, userID)
+	}
+	return err
+}
+
+func (c *cachedUsers) ConfirmEmailChange(ctx context.Context, token string) (*store.User, error) {
+	user, err := c.Users.ConfirmEmailChange(ctx, token)
+	if err == nil {
+		invalidateUser(ctx, c.cache, user.ID)
+	}
+	return user, err
+}
+
+func (c *cachedUsers) SoftDelete(ctx context.Context, userID int64) error {
+	err := c.Users.SoftDelete(ctx, userID)
+	if err == nil {
+		invalidateUser(ctx, c.cache, userID)
+	}
+	return err
+}
+
+func (c *cachedUsers) PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (store.Purged, error) {
+	purged, err := c.Users.PurgeDeleted(ctx, gracePeriod)
+	if err == nil {
+		for _, postID := range purged.PostIDs {
+			invalidatePost(ctx, c.posts, postID)
+		}
+	}
+	return purged, err
+}
+
+func (c *cachedUsers) UpdateRole(ctx context.Context, userID, roleID int64) error {
+	err := c.Users.UpdateRole(ctx, userID, roleID)
+	if err == nil {
+		invalidateUser(ctx, c.cache, userID)
+	}
+	return err
+}
+
+func (c *cachedUsers) Suspend(ctx context.Context, userID int64, reason string, until *time.Time) error {
+	err := c.Users.Suspend(ctx, userID, reason, until)
+	if err == nil {
+		invalidateUser(ctx, c.cache, userID)
+	}
+	return err
+}
+
+func (c *cachedUsers) Unsuspend(ctx context.Context, userID int64) error {
+	err := c.Users.Unsuspend(ctx, userID)
+	if err == nil {
+		invalidateUser(ctx, c.cache, userID)
+	}
+	return err
+}
+
+type cachedPosts struct {
+	store.Posts
+	cache Posts
+}
+
+// GetPostByID fills the cache from the primary: a lagging replica could
+// cache a post from before a change that was already invalidated.
+func (c *cachedPosts) GetPostByID(ctx context.Context, postID int) (*store.Post, error) {
+	return c.cache.Post(ctx, int64(postID), func(ctx context.Context) (*store.Post, error) {
+		return c.Posts.GetPostByID(store.WithPrimary(ctx), postID)
+	})
+}
+
+func (c *cachedPosts) UpdatePost(ctx context.Context, post *store.Post) error {
+	err := c.Posts.UpdatePost(ctx, post)
+	if err != nil {
+		// A conflict means the cached version is outdated as well, whether
+		// or not the transaction commits.
+		dropPost(ctx, c.cache, post.ID)
+		return err
+	}
+	invalidatePost(ctx, c.cache, post.ID)
+	return nil
+}
+
+func (c *cachedPosts) DeletePostByID(ctx context.Context, postID int64, version int) error {
+	err := c.Posts.DeletePostByID(ctx, postID, version)
+	if err != nil {
+		// Like for UpdatePost, a conflict means the cached version is
+		// outdated.
+		dropPost(ctx, c.cache, postID)
+		return err
+	}
+	invalidatePost(ctx, c.cache, postID)
+	return nil
+}
+
+type cachedComments struct {
+	store.Comments
+	cache Posts
+}
+
+// GetByPostID fills the cache from the primary, like GetPostByID.
+func (c *cachedComments) GetByPostID(ctx context.Context, postID int64) ([]*store.Comment, error) {
+	return c.cache.Comments(ctx, postID, func(ctx context.Context) ([]*store.Comment, error) {
+		return c.Comments.GetByPostID(store.WithPrimary(ctx), postID)
+	})
+}
+
+func (c *cachedComments) Create(ctx context.Context, comment *store.Comment) error {
+	err := c.Comments.Create(ctx, comment)
+	if err == nil {
+		invalidatePost(ctx, c.cache, comment.PostID)
+	}
+	return err
+}
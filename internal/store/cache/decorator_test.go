@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+type countingUserStore struct {
+	store.MockUserStore
+	loads int
+	// loading runs during a load, when set.
+	loading func()
+}
+
+func (c *countingUserStore) GetUserByID(ctx context.Context, id int64) (*store.User, error) {
+	c.loads++
+	if c.loading != nil {
+		c.loading()
+	}
+	if id < 0 {
+		return nil, store.ErrNotFound
+	}
+	return c.MockUserStore.GetUserByID(ctx, id)
+}
+
+func TestCachedUsers(t *testing.T) {
+	ctx := context.Background()
+	users := &countingUserStore{}
+	c := NewMemoryStorage(NewLRU(100, 0))
+	s := WithCache(store.Storage{Users: users}, c)
+
+	t.Run("Should drop the cached user when it changes", func(t *testing.T) {
+		for range 2 {
+			if _, err := s.Users.GetUserByID(ctx, 42); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if users.loads != 1 {
+			t.Errorf("Expected the user to be loaded once, but got %d loads", users.loads)
+		}
+
+		if err := s.Users.UpdateRole(ctx, 42, 3); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Users.GetUserByID(ctx, 42); err != nil {
+			t.Fatal(err)
+		}
+		if users.loads != 2 {
+			t.Errorf("Expected a reload after the role change, but got %d loads", users.loads)
+		}
+	})
+
+	t.Run("Should cache missing users", func(t *testing.T) {
+		users.loads = 0
+		for range 2 {
+			if _, err := s.Users.GetUserByID(ctx, -1); !errors.Is(err, store.ErrNotFound) {
+				t.Fatalf("Expected ErrNotFound, got %v", err)
+			}
+		}
+		if users.loads != 1 {
+			t.Errorf("Expected the missing user to be loaded once, but got %d loads", users.loads)
+		}
+	})
+
+	t.Run("Should not cache a user loaded before it was invalidated", func(t *testing.T) {
+		users.loads = 0
+		users.loading = func() {
+			// The change commits while the old row is being loaded.
+			if err := c.Users.Invalidate(ctx, 7); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := s.Users.GetUserByID(ctx, 7); err != nil {
+			t.Fatal(err)
+		}
+		users.loading = nil
+
+		if _, err := s.Users.GetUserByID(ctx, 7); err != nil {
+			t.Fatal(err)
+		}
+		if users.loads != 2 {
+			t.Errorf("Expected the user loaded before the invalidation to be reloaded, but got %d loads", users.loads)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// IdempotencyExpTime is how long a key and its stored response are kept.
+	IdempotencyExpTime = time.Hour * 24
+
+	// IdempotencyLockTime is how long a key is reserved for a request in
+	// progress. It outlives the server's 30s write timeout, so a key only
+	// expires early when the process died before completing it.
+	IdempotencyLockTime = time.Minute
+)
+
+// IdempotentRequest is what is remembered about a request sent with an
+// Idempotency-Key, the response is only set once the request completed.
+type IdempotentRequest struct {
+	Fingerprint string              `json:"fingerprint"`
+	Completed   bool                `json:"completed"`
+	Status      int                 `json:"status,omitempty"`
+	Header      map[string][]string `json:"header,omitempty"`
+	Body        []byte              `json:"body,omitempty"`
+}
+
+type IdempotencyStore struct {
+	db backend
+}
+
+// Reserve stores an in progress request for key unless one already exists,
+// for IdempotencyLockTime until Complete keeps it for IdempotencyExpTime.
+// It reports whether the key was reserved, otherwise the existing request is
+// returned.
+func (s *IdempotencyStore) Reserve(ctx context.Context, key, fingerprint string) (*IdempotentRequest, bool, error) {
+	cacheKey := "idempotency-" + key
+
+	ctx, span := startSpan(ctx, s.db, "idempotency.Reserve", cacheKey)
+
+	req := &IdempotentRequest{Fingerprint: fingerprint}
+	data, err := json.Marshal(req)
+	if err != nil {
+		endSpan(span, err)
+		return nil, false, err
+	}
+
+	reserved, err := s.db.SetNX(ctx, cacheKey, data, IdempotencyLockTime)
+	if err != nil || reserved {
+		endSpan(span, err)
+		return req, reserved, err
+	}
+
+	existing, ok, err := s.db.Get(ctx, cacheKey)
+	if err == nil && !ok {
+		// Expired between both calls.
+		err = errors.New("idempotency key expired while reserving it")
+	}
+	endSpan(span, err)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var stored IdempotentRequest
+	if err := json.Unmarshal(existing, &stored); err != nil {
+		return nil, false, err
+	}
+
+	return &stored, false, nil
+}
+
+func (s *IdempotencyStore) Complete(ctx context.Context, key string, req *IdempotentRequest) error {
+	cacheKey := "idempotency-" + key
+
+	req.Completed = true
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	ctx, span := startSpan(ctx, s.db, "idempotency.Complete", cacheKey)
+	err = s.db.Set(ctx, cacheKey, data, IdempotencyExpTime)
+	endSpan(span, err)
+	return err
+}
+
+// Release forgets key, so the request can be retried.
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	cacheKey := "idempotency-" + key
+
+	ctx, span := startSpan(ctx, s.db, "idempotency.Release", cacheKey)
+	err := s.db.Del(ctx, cacheKey)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/metrics"
+)
+
+// LRU is a size bounded in-process backend. Entries expire after their
+// ttl, and the least recently used entry is evicted when it is full.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	maxTTL  time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+// NewLRU returns an LRU holding at most size entries. A maxTTL above zero
+// caps the ttl of every entry.
+func NewLRU(size int, maxTTL time.Duration) *LRU {
+	return &LRU{
+		size:    size,
+		maxTTL:  maxTTL,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (l *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.entries[key]
+	if !ok {
+		metrics.LocalCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false, nil
+	}
+
+	entry := el.Value.(*lruEntry)
+	if !l.now().Before(entry.expiresAt) {
+		l.remove(el)
+		metrics.LocalCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false, nil
+	}
+
+	l.order.MoveToFront(el)
+	metrics.LocalCacheRequests.WithLabelValues("hit").Inc()
+	return entry.data, true, nil
+}
+
+func (l *LRU) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.set(key, data, ttl)
+	return nil
+}
+
+func (l *LRU) SetNX(ctx context.Context, key string, data []byte, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.entries[key]; ok && l.now().Before(el.Value.(*lruEntry).expiresAt) {
+		return false, nil
+	}
+
+	l.set(key, data, ttl)
+	return true, nil
+}
+
+func (l *LRU) Del(ctx context.Context, keys ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := l.entries[key]; ok {
+			l.remove(el)
+		}
+	}
+	return nil
+}
+
+func (l *LRU) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var n int64
+	if el, ok := l.entries[key]; ok && l.now().Before(el.Value.(*lruEntry).expiresAt) {
+		n, _ = strconv.ParseInt(string(el.Value.(*lruEntry).data), 10, 64)
+	}
+	n++
+
+	l.set(key, []byte(strconv.FormatInt(n, 10)), ttl)
+	return n, nil
+}
+
+func (l *LRU) System() string {
+	return "memory"
+}
+
+// Clear drops every entry.
+func (l *LRU) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.order.Len() > 0 {
+		l.remove(l.order.Back())
+	}
+}
+
+// Len returns the number of entries, including expired ones that were not
+// evicted yet.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) set(key string, data []byte, ttl time.Duration) {
+	if l.maxTTL > 0 && (ttl <= 0 || ttl > l.maxTTL) {
+		ttl = l.maxTTL
+	}
+	expiresAt := l.now().Add(ttl)
+
+	if el, ok := l.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.data = data
+		entry.expiresAt = expiresAt
+		l.order.MoveToFront(el)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, data: data, expiresAt: expiresAt})
+	metrics.LocalCacheEntries.Inc()
+
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+		metrics.LocalCacheEvictions.Inc()
+	}
+}
+
+func (l *LRU) remove(el *list.Element) {
+	l.order.Remove(el)
+	delete(l.entries, el.Value.(*lruEntry).key)
+	metrics.LocalCacheEntries.Dec()
+}
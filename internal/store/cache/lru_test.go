@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Should evict the least recently used entry", func(t *testing.T) {
+		lru := NewLRU(2, 0)
+		lru.Set(ctx, "a", []byte("1"), time.Minute)
+		lru.Set(ctx, "b", []byte("2"), time.Minute)
+		lru.Get(ctx, "a")
+		lru.Set(ctx, "c", []byte("3"), time.Minute)
+
+		if _, ok, _ := lru.Get(ctx, "b"); ok {
+			t.Error("Expected b to be evicted")
+		}
+		if _, ok, _ := lru.Get(ctx, "a"); !ok {
+			t.Error("Expected a to be kept")
+		}
+	})
+
+	t.Run("Should expire entries after their ttl", func(t *testing.T) {
+		now := time.Now()
+		lru := NewLRU(10, time.Second*30)
+		lru.now = func() time.Time { return now }
+
+		lru.Set(ctx, "a", []byte("1"), time.Minute)
+
+		now = now.Add(time.Second * 31)
+		if _, ok, _ := lru.Get(ctx, "a"); ok {
+			t.Error("Expected a to expire after the max ttl")
+		}
+	})
+
+	t.Run("Should increment counters", func(t *testing.T) {
+		lru := NewLRU(10, 0)
+		lru.Incr(ctx, "n", time.Minute)
+		if n, _ := lru.Incr(ctx, "n", time.Minute); n != 2 {
+			t.Errorf("Expected 2, but got %d", n)
+		}
+	})
+
+	t.Run("Should drop every entry when cleared", func(t *testing.T) {
+		lru := NewLRU(10, 0)
+		lru.Set(ctx, "a", []byte("1"), time.Minute)
+		lru.Set(ctx, "b", []byte("2"), time.Minute)
+
+		lru.Clear()
+		if n := lru.Len(); n != 0 {
+			t.Errorf("Expected no entries, but got %d", n)
+		}
+	})
+}
@@ -8,16 +8,46 @@ import (
 
 func NewMockCacheStorage() Storage {
 	return Storage{
-		Users: &mockUserStore{},
+		Users:       &mockUserStore{},
+		Posts:       &mockPostStore{},
+		Idempotency: &mockIdempotencyStore{},
 	}
 }
 
 type mockUserStore struct{}
 
-func (m *mockUserStore) Get(ctx context.Context, id int64) (*store.User, error) {
-	return nil, nil
+func (m *mockUserStore) User(ctx context.Context, id int64, load func(context.Context) (*store.User, error)) (*store.User, error) {
+	return load(ctx)
 }
 
-func (m *mockUserStore) Set(ctx context.Context, u *store.User) error {
+func (m *mockUserStore) Invalidate(ctx context.Context, id int64) error {
+	return nil
+}
+
+type mockIdempotencyStore struct{}
+
+func (m *mockIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string) (*IdempotentRequest, bool, error) {
+	return &IdempotentRequest{Fingerprint: fingerprint}, true, nil
+}
+
+func (m *mockIdempotencyStore) Complete(ctx context.Context, key string, req *IdempotentRequest) error {
+	return nil
+}
+
+func (m *mockIdempotencyStore) Release(ctx context.Context, key string) error {
+	return nil
+}
+
+type mockPostStore struct{}
+
+func (m *mockPostStore) Post(ctx context.Context, postID int64, load func(context.Context) (*store.Post, error)) (*store.Post, error) {
+	return load(ctx)
+}
+
+func (m *mockPostStore) Comments(ctx context.Context, postID int64, load func(context.Context) ([]*store.Comment, error)) ([]*store.Comment, error) {
+	return load(ctx)
+}
+
+func (m *mockPostStore) Invalidate(ctx context.Context, postID int64) error {
 	return nil
 }
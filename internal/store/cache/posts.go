@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/logger"
+	"github.com/MohummedSoliman/social/internal/metrics"
+	"github.com/MohummedSoliman/social/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	PostExpTime = time.Minute * 5
+
+	// postGenerationExpTime must outlive PostExpTime, so a generation only
+	// starts over once no entry of it is left.
+	postGenerationExpTime = time.Hour * 24
+)
+
+// PostStore caches posts and their comments. Keys carry a generation that
+// every invalidation bumps: a reader that loaded a post before it changed
+// writes it under the old generation, where nobody looks anymore.
+type PostStore struct {
+	db    backend
+	group singleflight.Group
+}
+
+func (p *PostStore) Post(ctx context.Context, postID int64, load func(context.Context) (*store.Post, error)) (*store.Post, error) {
+	var post store.Post
+	err := p.getOrLoad(ctx, "posts", postID, "post", &post, func(ctx context.Context) (any, error) {
+		return load(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+func (p *PostStore) Comments(ctx context.Context, postID int64, load func(context.Context) ([]*store.Comment, error)) ([]*store.Comment, error) {
+	var comments []*store.Comment
+	err := p.getOrLoad(ctx, "comments", postID, "comments", &comments, func(ctx context.Context) (any, error) {
+		return load(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (p *PostStore) Invalidate(ctx context.Context, postID int64) error {
+	genKey := generationKey(postID)
+
+	ctx, span := startSpan(ctx, p.db, "posts.Invalidate", genKey)
+	_, err := p.db.Incr(ctx, genKey, postGenerationExpTime)
+	endSpan(span, err)
+	return err
+}
+
+// getOrLoad decodes the cached entry into dst, or loads, caches and decodes
+// it. Concurrent misses of the same entry share one load, every caller gets
+// its own copy. The cache failing falls back to load.
+func (p *PostStore) getOrLoad(ctx context.Context, cache string, postID int64, kind string, dst any, load func(context.Context) (any, error)) error {
+	log := logger.FromContext(ctx)
+
+	gen, err := p.generation(ctx, postID)
+	if err != nil && !errors.Is(err, ErrUnavailable) {
+		log.Warn("error reading post cache generation", "post_id", postID, "error", err.Error())
+	}
+
+	cacheKey := fmt.Sprintf("post-%d-g%d-%s", postID, gen, kind)
+
+	if err == nil {
+		data, hit, err := p.get(ctx, cache, cacheKey)
+		if err != nil && !errors.Is(err, ErrUnavailable) {
+			log.Warn("error reading post cache", "key", cacheKey, "error", err.Error())
+		}
+		if hit {
+			return json.Unmarshal(data, dst)
+		}
+	}
+
+	data, err, _ := p.group.Do(cacheKey, func() (any, error) {
+		// The load is shared, so it must not be cancelled with the caller
+		// that happened to start it.
+		ctx := context.WithoutCancel(ctx)
+
+		v, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, span := startSpan(ctx, p.db, "posts.Set", cacheKey)
+		err = p.db.Set(ctx, cacheKey, data, PostExpTime)
+		endSpan(span, err)
+		if err != nil && !errors.Is(err, ErrUnavailable) {
+			log.Warn("error writing post cache", "key", cacheKey, "error", err.Error())
+		}
+
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data.([]byte), dst)
+}
+
+func (p *PostStore) generation(ctx context.Context, postID int64) (int64, error) {
+	data, ok, err := p.db.Get(ctx, generationKey(postID))
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+func (p *PostStore) get(ctx context.Context, cache, cacheKey string) ([]byte, bool, error) {
+	ctx, span := startSpan(ctx, p.db, "posts.Get", cacheKey)
+	data, ok, err := p.db.Get(ctx, cacheKey)
+	switch {
+	case err != nil:
+		metrics.CacheRequests.WithLabelValues(cache, errorResult(err)).Inc()
+		endSpan(span, err)
+		return nil, false, err
+	case !ok:
+		metrics.CacheRequests.WithLabelValues(cache, "miss").Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+		endSpan(span, nil)
+		return nil, false, nil
+	default:
+		metrics.CacheRequests.WithLabelValues(cache, "hit").Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		endSpan(span, nil)
+		return data, true, nil
+	}
+}
+
+func generationKey(postID int64) string {
+	return fmt.Sprintf("post-%d-gen", postID)
+}
@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/store"
+)
+
+type countingCommentStore struct {
+	store.Comments
+	loads   atomic.Int64
+	primary atomic.Bool
+}
+
+func (c *countingCommentStore) GetByPostID(ctx context.Context, postID int64) ([]*store.Comment, error) {
+	c.loads.Add(1)
+	c.primary.Store(store.PinnedToPrimary(ctx))
+	return c.Comments.GetByPostID(ctx, postID)
+}
+
+type recordingPostCache struct {
+	Posts
+	invalidations atomic.Int64
+}
+
+func (r *recordingPostCache) Invalidate(ctx context.Context, postID int64) error {
+	r.invalidations.Add(1)
+	return r.Posts.Invalidate(ctx, postID)
+}
+
+func TestCachedPosts(t *testing.T) {
+	ctx := context.Background()
+
+	db := store.NewMemoryStorage()
+	comments := &countingCommentStore{Comments: db.Comments}
+	db.Comments = comments
+	c := NewMemoryStorage(NewLRU(100, 0))
+	postCache := &recordingPostCache{Posts: c.Posts}
+	c.Posts = postCache
+	s := WithCache(db, c)
+
+	if err := s.Users.Create(ctx, &store.User{ID: 1, Username: "gopher", Email: "gopher@example.com", RoleID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	newPost := func(t *testing.T) *store.Post {
+		t.Helper()
+		post := &store.Post{Title: "title", Content: "content", UserID: 1}
+		if err := s.Posts.Create(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+		// Fill the cache.
+		if _, err := s.Posts.GetPostByID(ctx, int(post.ID)); err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+
+	t.Run("Should reload a post after it is updated", func(t *testing.T) {
+		post := newPost(t)
+
+		post.Title = "updated"
+		if err := s.Posts.UpdatePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+
+		cached, err := s.Posts.GetPostByID(ctx, int(post.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached.Title != "updated" {
+			t.Errorf("Expected the updated title, but got %q", cached.Title)
+		}
+	})
+
+	t.Run("Should not serve a deleted post", func(t *testing.T) {
+		post := newPost(t)
+
+		if err := s.Posts.DeletePostByID(ctx, post.ID, post.Version); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Posts.GetPostByID(ctx, int(post.ID)); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, but got %v", err)
+		}
+	})
+
+	t.Run("Should not serve the posts of purged users", func(t *testing.T) {
+		if err := s.Users.Create(ctx, &store.User{ID: 2, Username: "purged", Email: "purged@example.com", RoleID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		post := &store.Post{Title: "title", Content: "content", UserID: 2}
+		if err := s.Posts.Create(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Posts.GetPostByID(ctx, int(post.ID)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Users.SoftDelete(ctx, 2); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Users.PurgeDeleted(ctx, -time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Posts.GetPostByID(ctx, int(post.ID)); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, but got %v", err)
+		}
+	})
+
+	t.Run("Should fill the cache from the primary", func(t *testing.T) {
+		post := newPost(t)
+		if _, err := s.Comments.GetByPostID(ctx, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		if !comments.primary.Load() {
+			t.Error("Expected the comments to be loaded from the primary")
+		}
+	})
+
+	t.Run("Should invalidate the comments once a new comment is committed", func(t *testing.T) {
+		post := newPost(t)
+		if _, err := s.Comments.GetByPostID(ctx, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		invalidations := postCache.invalidations.Load()
+
+		err := s.Tx.InTx(ctx, nil, func(txCtx context.Context) error {
+			if err := s.Comments.Create(txCtx, &store.Comment{PostID: post.ID, UserID: 1, Content: "comment"}); err != nil {
+				return err
+			}
+
+			// A reader outside the transaction could fill the cache again
+			// with the comments from before the commit.
+			if n := postCache.invalidations.Load() - invalidations; n != 0 {
+				t.Errorf("Expected no invalidation before the commit, but got %d", n)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := postCache.invalidations.Load() - invalidations; n != 1 {
+			t.Errorf("Expected one invalidation after the commit, but got %d", n)
+		}
+
+		got, err := s.Comments.GetByPostID(ctx, post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Errorf("Expected the new comment, but got %d comments", len(got))
+		}
+	})
+
+	t.Run("Should keep the comments cached when the transaction rolls back", func(t *testing.T) {
+		post := newPost(t)
+		if _, err := s.Comments.GetByPostID(ctx, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		loads := comments.loads.Load()
+
+		rollback := errors.New("rollback")
+		err := s.Tx.InTx(ctx, nil, func(txCtx context.Context) error {
+			if err := s.Comments.Create(txCtx, &store.Comment{PostID: post.ID, UserID: 1, Content: "comment"}); err != nil {
+				return err
+			}
+			return rollback
+		})
+		if !errors.Is(err, rollback) {
+			t.Fatalf("Expected the rollback error, but got %v", err)
+		}
+
+		if _, err := s.Comments.GetByPostID(ctx, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		if n := comments.loads.Load() - loads; n != 0 {
+			t.Errorf("Expected the cached comments to be served, but got %d loads", n)
+		}
+	})
+}
+
+func TestPostStore(t *testing.T) {
+	ctx := context.Background()
+	posts := NewMemoryStorage(NewLRU(100, 0)).Posts
+
+	loads := 0
+	load := func(ctx context.Context) (*store.Post, error) {
+		loads++
+		return &store.Post{ID: 1, Title: "title", Version: loads}, nil
+	}
+
+	t.Run("Should load a post once until it is invalidated", func(t *testing.T) {
+		for range 3 {
+			post, err := posts.Post(ctx, 1, load)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if post.Version != 1 {
+				t.Errorf("Expected the cached version 1, but got %d", post.Version)
+			}
+		}
+
+		if err := posts.Invalidate(ctx, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		post, err := posts.Post(ctx, 1, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if post.Version != 2 || loads != 2 {
+			t.Errorf("Expected a reload after invalidation, but got version %d after %d loads", post.Version, loads)
+		}
+	})
+}
+
+func TestPostStoreSharesLoads(t *testing.T) {
+	ctx := context.Background()
+	posts := NewMemoryStorage(NewLRU(100, 0)).Posts
+
+	var loads atomic.Int64
+	release := make(chan struct{})
+	load := func(ctx context.Context) (*store.Post, error) {
+		loads.Add(1)
+		<-release
+		return &store.Post{ID: 1, Title: "title"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			post, err := posts.Post(ctx, 1, load)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			// Every caller gets its own copy.
+			post.Title = "changed"
+		}()
+	}
+
+	// Give every caller the time to join the first load.
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected concurrent misses to share one load, but got %d loads", n)
+	}
+
+	post, err := posts.Post(ctx, 1, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Title != "title" || loads.Load() != 1 {
+		t.Errorf("Expected the shared load to be cached unchanged, but got %q after %d loads", post.Title, loads.Load())
+	}
+}
@@ -2,22 +2,78 @@ package cache
 
 import (
 	"context"
+	"log/slog"
 
 	"github.com/MohummedSoliman/social/internal/store"
 	"github.com/go-redis/redis/v8"
 )
 
 type Storage struct {
-	Users Users
+	Users       Users
+	Posts       Posts
+	Idempotency Idempotency
+
+	// run keeps the backend in sync with other instances, if it needs to.
+	run func(context.Context) error
 }
 
 func NewRedisStorage(rdb *redis.Client) Storage {
+	db := newBreaker(&redisBackend{rdb})
+	return newStorage(db, db)
+}
+
+// NewMemoryStorage keeps the caches in process, for running a single
+// instance without Redis. Idempotency keys are kept apart from l1 so that
+// filling the caches can not evict them before they expire.
+func NewMemoryStorage(l1 *LRU) Storage {
+	return newStorage(l1, newTTLMap())
+}
+
+// NewTieredStorage serves the caches from the local l1 in front of Redis.
+// Run must be called to receive the invalidations of other instances.
+func NewTieredStorage(rdb *redis.Client, l1 *LRU, logger *slog.Logger) Storage {
+	tiered := newTieredBackend(rdb, l1, logger)
+
+	s := newStorage(tiered, tiered.l2)
+	s.run = tiered.run
+	return s
+}
+
+// newStorage builds the caches on db. Idempotency keys must be seen by every
+// instance at once, so they are kept in shared.
+func newStorage(db backend, shared backend) Storage {
 	return Storage{
-		Users: &UserStore{rdb},
+		Users:       &UserStore{db},
+		Posts:       &PostStore{db: db},
+		Idempotency: &IdempotencyStore{shared},
 	}
 }
 
+// Run syncs the caches with other instances until ctx is done.
+func (s Storage) Run(ctx context.Context) error {
+	if s.run == nil {
+		return nil
+	}
+	return s.run(ctx)
+}
+
+// Users returns cached users by ID, calling load to fill the cache on a
+// miss. Users load does not find are cached as missing.
 type Users interface {
-	Get(context.Context, int64) (*store.User, error)
-	Set(context.Context, *store.User) error
+	User(ctx context.Context, userID int64, load func(context.Context) (*store.User, error)) (*store.User, error)
+	Invalidate(context.Context, int64) error
+}
+
+// Posts returns cached posts and comments, calling load to fill the cache
+// on a miss.
+type Posts interface {
+	Post(ctx context.Context, postID int64, load func(context.Context) (*store.Post, error)) (*store.Post, error)
+	Comments(ctx context.Context, postID int64, load func(context.Context) ([]*store.Comment, error)) ([]*store.Comment, error)
+	Invalidate(context.Context, int64) error
+}
+
+type Idempotency interface {
+	Reserve(ctx context.Context, key, fingerprint string) (*IdempotentRequest, bool, error)
+	Complete(context.Context, string, *IdempotentRequest) error
+	Release(context.Context, string) error
 }
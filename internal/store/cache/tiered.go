@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/metrics"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	invalidationChannel = "cache-invalidation"
+
+	// tombstoneExpTime outlives the entries a failed invalidation may have
+	// left in Redis.
+	tombstoneExpTime = PostExpTime
+)
+
+// tieredBackend serves reads from a local L1 in front of Redis. Deleted and
+// incremented keys are broadcast, so every instance drops them from its L1.
+// Set is not broadcast: changed values are invalidated with Del.
+//
+// A key that could not be invalidated in Redis is tombstoned: until the
+// stale entry expires, reads of it report the cache unavailable, so the
+// callers load it from the database even once Redis is back. Other
+// instances cannot be told while Redis is down, they keep their L1 entries
+// until its short ttl ends or until they subscribe again, which clears it.
+type tieredBackend struct {
+	l1         *LRU
+	l2         backend
+	tombstones *ttlMap
+	rdb        *redis.Client
+	logger     *slog.Logger
+}
+
+func (b *tieredBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if _, ok, _ := b.tombstones.Get(ctx, key); ok {
+		return nil, false, ErrUnavailable
+	}
+
+	if data, ok, _ := b.l1.Get(ctx, key); ok {
+		return data, true, nil
+	}
+
+	data, ok, err := b.l2.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	// The L1 caps the ttl, the remaining one of Redis is not known here.
+	b.l1.Set(ctx, key, data, 0)
+	return data, true, nil
+}
+
+func (b *tieredBackend) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if err := b.l2.Set(ctx, key, data, ttl); err != nil {
+		return err
+	}
+	return b.l1.Set(ctx, key, data, ttl)
+}
+
+// SetNX only goes to Redis, the winner has to be decided across instances.
+func (b *tieredBackend) SetNX(ctx context.Context, key string, data []byte, ttl time.Duration) (bool, error) {
+	return b.l2.SetNX(ctx, key, data, ttl)
+}
+
+// Del drops keys from every tier it can reach, Redis failing does not keep
+// them in the L1 of any instance.
+func (b *tieredBackend) Del(ctx context.Context, keys ...string) error {
+	err := b.l2.Del(ctx, keys...)
+	if err != nil {
+		b.tombstone(ctx, keys...)
+	}
+	b.l1.Del(ctx, keys...)
+	return errors.Join(err, b.broadcast(ctx, keys))
+}
+
+func (b *tieredBackend) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	n, err := b.l2.Incr(ctx, key, ttl)
+	if err != nil {
+		b.tombstone(ctx, key)
+	}
+	b.l1.Del(ctx, key)
+	return n, errors.Join(err, b.broadcast(ctx, []string{key}))
+}
+
+func (b *tieredBackend) tombstone(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		b.tombstones.Set(ctx, key, nil, tombstoneExpTime)
+	}
+}
+
+func (b *tieredBackend) System() string {
+	return "memory+redis"
+}
+
+func (b *tieredBackend) broadcast(ctx context.Context, keys []string) error {
+	data, err := json.Marshal(keys)
+	if err == nil {
+		err = b.rdb.Publish(ctx, invalidationChannel, data).Err()
+	}
+	if err != nil {
+		metrics.CacheBroadcastFailures.Inc()
+		b.logger.Warn("error broadcasting cache invalidation", "keys", keys, "error", err.Error())
+	}
+	return err
+}
+
+// run drops the keys other instances invalidated from the L1 until ctx is
+// done.
+func (b *tieredBackend) run(ctx context.Context) error {
+	// The channel of go-redis reconnects on its own if the connection drops,
+	// and reports every subscription.
+	pubsub := b.rdb.Subscribe(ctx, invalidationChannel)
+	defer pubsub.Close()
+
+	subscribed := false
+	ch := pubsub.ChannelWithSubscriptions(ctx, 100)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			switch msg := msg.(type) {
+			case *redis.Subscription:
+				if msg.Kind != "subscribe" {
+					continue
+				}
+				// Invalidations broadcast while disconnected were missed.
+				if subscribed {
+					b.logger.Warn("cache invalidations resubscribed, clearing the local cache")
+					b.l1.Clear()
+				}
+				subscribed = true
+			case *redis.Message:
+				var keys []string
+				if err := json.Unmarshal([]byte(msg.Payload), &keys); err != nil {
+					b.logger.Error("error decoding cache invalidation", "error", err.Error())
+					continue
+				}
+				b.l1.Del(ctx, keys...)
+			}
+		}
+	}
+}
+
+func newTieredBackend(rdb *redis.Client, l1 *LRU, logger *slog.Logger) *tieredBackend {
+	return &tieredBackend{
+		l1:         l1,
+		l2:         newBreaker(&redisBackend{rdb}),
+		tombstones: newTTLMap(),
+		rdb:        rdb,
+		logger:     logger,
+	}
+}
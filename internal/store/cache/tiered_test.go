@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/metrics"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTieredBackend(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	l2 := &failingBackend{LRU: NewLRU(10, 0)}
+	// Nothing listens there, so the broadcasts fail like Redis being down.
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rdb.Close()
+
+	b := newTieredBackend(rdb, NewLRU(10, 0), slog.New(slog.DiscardHandler))
+	b.l2 = l2
+	b.tombstones.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b"} {
+		if err := b.Set(ctx, key, []byte("stale"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l2.err = errors.New("connection refused")
+
+	t.Run("Should drop the local entry when Redis fails", func(t *testing.T) {
+		failures := testutil.ToFloat64(metrics.CacheBroadcastFailures)
+
+		if err := b.Del(ctx, "a"); err == nil {
+			t.Error("Expected the Redis error")
+		}
+		if _, err := b.Incr(ctx, "b", time.Minute); err == nil {
+			t.Error("Expected the Redis error")
+		}
+
+		for _, key := range []string{"a", "b"} {
+			if _, ok, _ := b.l1.Get(ctx, key); ok {
+				t.Errorf("Expected %q to be dropped from the L1", key)
+			}
+		}
+
+		if n := testutil.ToFloat64(metrics.CacheBroadcastFailures) - failures; n != 2 {
+			t.Errorf("Expected the failed broadcasts to be counted, but got %v", n)
+		}
+	})
+
+	t.Run("Should not serve the stale entry once Redis recovers", func(t *testing.T) {
+		l2.err = nil
+
+		for _, key := range []string{"a", "b"} {
+			if _, _, err := b.Get(ctx, key); !errors.Is(err, ErrUnavailable) {
+				t.Errorf("Expected ErrUnavailable for %q, got %v", key, err)
+			}
+		}
+
+		now = now.Add(tombstoneExpTime)
+		data, ok, err := b.Get(ctx, "a")
+		if err != nil || !ok || string(data) != "stale" {
+			t.Errorf("Expected the tombstone to expire, got %q, %v, %v", data, ok, err)
+		}
+	})
+}
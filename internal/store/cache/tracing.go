@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/MohummedSoliman/social/internal/store/cache")
+
+func startSpan(ctx context.Context, db backend, name, key string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "cache."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", db.System()),
+			attribute.String("db.operation.name", name),
+			attribute.String("cache.key", key),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ttlSweepInterval is how often expired entries are dropped from a ttlMap.
+const ttlSweepInterval = time.Minute
+
+// ttlMap is an in-process backend whose entries only go away when their ttl
+// expires. Unlike the LRU it never evicts, for entries that must not be
+// forgotten early, so its size is bound by the ttl times the write rate.
+type ttlMap struct {
+	mu        sync.Mutex
+	entries   map[string]ttlEntry
+	now       func() time.Time
+	nextSweep time.Time
+}
+
+type ttlEntry struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func newTTLMap() *ttlMap {
+	return &ttlMap{
+		entries: make(map[string]ttlEntry),
+		now:     time.Now,
+	}
+}
+
+func (m *ttlMap) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.get(key)
+	return entry.data, ok, nil
+}
+
+func (m *ttlMap) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, data, ttl)
+	return nil
+}
+
+func (m *ttlMap) SetNX(ctx context.Context, key string, data []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.get(key); ok {
+		return false, nil
+	}
+
+	m.set(key, data, ttl)
+	return true, nil
+}
+
+func (m *ttlMap) Del(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *ttlMap) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	if entry, ok := m.get(key); ok {
+		n, _ = strconv.ParseInt(string(entry.data), 10, 64)
+	}
+	n++
+
+	m.set(key, []byte(strconv.FormatInt(n, 10)), ttl)
+	return n, nil
+}
+
+func (m *ttlMap) System() string {
+	return "memory"
+}
+
+// Len returns the number of entries, including expired ones that were not
+// swept yet.
+func (m *ttlMap) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+func (m *ttlMap) get(key string) (ttlEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return ttlEntry{}, false
+	}
+	if !m.now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return ttlEntry{}, false
+	}
+	return entry, true
+}
+
+func (m *ttlMap) set(key string, data []byte, ttl time.Duration) {
+	now := m.now()
+	m.entries[key] = ttlEntry{data: data, expiresAt: now.Add(ttl)}
+
+	// Entries that are never read again are dropped here.
+	if now.Before(m.nextSweep) {
+		return
+	}
+	for key, entry := range m.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+	m.nextSweep = now.Add(ttlSweepInterval)
+}
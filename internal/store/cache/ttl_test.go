@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTTLMap(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := newTTLMap()
+	m.now = func() time.Time { return now }
+
+	t.Run("Should keep entries until their ttl expires", func(t *testing.T) {
+		if err := m.Set(ctx, "a", []byte("1"), time.Minute*10); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(time.Minute * 5)
+		if _, ok, _ := m.Get(ctx, "a"); !ok {
+			t.Error("Expected the entry before its ttl")
+		}
+
+		now = now.Add(time.Minute * 5)
+		if _, ok, _ := m.Get(ctx, "a"); ok {
+			t.Error("Expected the entry to expire")
+		}
+	})
+
+	t.Run("Should drop expired entries that are not read again", func(t *testing.T) {
+		for i := range 100 {
+			if err := m.Set(ctx, strconv.Itoa(i), []byte("1"), time.Second); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		now = now.Add(ttlSweepInterval)
+		if err := m.Set(ctx, "b", []byte("1"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if n := m.Len(); n != 1 {
+			t.Errorf("Expected the expired entries to be swept, but got %d entries", n)
+		}
+	})
+}
+
+func TestMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage(NewLRU(1, 0))
+
+	t.Run("Should not evict idempotency keys when the cache is full", func(t *testing.T) {
+		if _, reserved, err := s.Idempotency.Reserve(ctx, "key", "fingerprint"); err != nil || !reserved {
+			t.Fatalf("Expected the key to be reserved, got %v, %v", reserved, err)
+		}
+
+		for id := range int64(10) {
+			if err := s.Posts.Invalidate(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, reserved, err := s.Idempotency.Reserve(ctx, "key", "fingerprint"); err != nil || reserved {
+			t.Errorf("Expected the key to still be reserved, got %v, %v", reserved, err)
+		}
+	})
+
+	t.Run("Should only keep a reservation that never completes for the lock time", func(t *testing.T) {
+		now := time.Now()
+		m := newTTLMap()
+		m.now = func() time.Time { return now }
+		s := &IdempotencyStore{m}
+
+		if _, reserved, err := s.Reserve(ctx, "crashed", "fingerprint"); err != nil || !reserved {
+			t.Fatalf("Expected the key to be reserved, got %v, %v", reserved, err)
+		}
+		req, reserved, err := s.Reserve(ctx, "completed", "fingerprint")
+		if err != nil || !reserved {
+			t.Fatalf("Expected the key to be reserved, got %v, %v", reserved, err)
+		}
+		if err := s.Complete(ctx, "completed", req); err != nil {
+			t.Fatal(err)
+		}
+
+		now = now.Add(IdempotencyLockTime)
+		if _, reserved, err := s.Reserve(ctx, "crashed", "fingerprint"); err != nil || !reserved {
+			t.Errorf("Expected the abandoned key to be reserved again, got %v, %v", reserved, err)
+		}
+		if stored, reserved, err := s.Reserve(ctx, "completed", "fingerprint"); err != nil || reserved || !stored.Completed {
+			t.Errorf("Expected the completed request to be kept, got %v, %v", reserved, err)
+		}
+	})
+}
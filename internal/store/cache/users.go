@@ -3,49 +3,137 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/MohummedSoliman/social/internal/logger"
+	"github.com/MohummedSoliman/social/internal/metrics"
 	"github.com/MohummedSoliman/social/internal/store"
-	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// UserStore caches users with generation keys like PostStore, so a user
+// loaded before an invalidation is never cached over it.
 type UserStore struct {
-	db *redis.Client
+	db backend
 }
 
-const UserExpTime = time.Minute
+const (
+	UserExpTime = time.Minute
 
-func (u *UserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
-	cacheKey := fmt.Sprintf("user-%v", userID)
-	data, err := u.db.Get(ctx, cacheKey).Result()
-	if err != nil {
-		switch err {
-		case redis.Nil:
-			return nil, nil
-		default:
-			return nil, err
-		}
+	// MissingUserExpTime is kept short, a user created meanwhile is only
+	// found once it expired unless it was invalidated.
+	MissingUserExpTime = time.Second * 10
+
+	// userGenerationExpTime must outlive UserExpTime, like
+	// postGenerationExpTime.
+	userGenerationExpTime = time.Hour * 24
+)
+
+// User returns the cached user, or loads and caches it. A user load does
+// not find is cached as missing and returned as store.ErrNotFound. The
+// cache failing falls back to load.
+func (u *UserStore) User(ctx context.Context, userID int64, load func(context.Context) (*store.User, error)) (*store.User, error) {
+	log := logger.FromContext(ctx)
+
+	gen, err := u.generation(ctx, userID)
+	if err != nil && !errors.Is(err, ErrUnavailable) {
+		log.Warn("error reading user cache generation", "user_id", userID, "error", err.Error())
 	}
 
-	var user store.User
-	if data != "" {
-		err := json.Unmarshal([]byte(data), &user)
-		if err != nil {
+	cacheKey := fmt.Sprintf("user-%d-g%d", userID, gen)
+
+	if err == nil {
+		user, err := u.get(ctx, cacheKey)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
 			return nil, err
+		case err != nil && !errors.Is(err, ErrUnavailable):
+			log.Warn("error reading user cache", "user_id", userID, "error", err.Error())
+		case user != nil:
+			return user, nil
 		}
 	}
 
-	return &user, nil
+	user, err := load(ctx)
+	if errors.Is(err, store.ErrNotFound) {
+		u.set(ctx, cacheKey, []byte{}, MissingUserExpTime)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	u.set(ctx, cacheKey, data, UserExpTime)
+
+	return user, nil
 }
 
-func (u *UserStore) Set(ctx context.Context, user *store.User) error {
-	cacheKey := fmt.Sprintf("user-%v", user.ID)
+func (u *UserStore) Invalidate(ctx context.Context, userID int64) error {
+	genKey := userGenerationKey(userID)
 
-	json, err := json.Marshal(user)
+	ctx, span := startSpan(ctx, u.db, "users.Invalidate", genKey)
+	_, err := u.db.Incr(ctx, genKey, userGenerationExpTime)
+	endSpan(span, err)
+	return err
+}
+
+func (u *UserStore) generation(ctx context.Context, userID int64) (int64, error) {
+	data, ok, err := u.db.Get(ctx, userGenerationKey(userID))
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+// get returns nil on a miss and store.ErrNotFound for a user cached as
+// missing, an empty entry marks it.
+func (u *UserStore) get(ctx context.Context, cacheKey string) (*store.User, error) {
+	ctx, span := startSpan(ctx, u.db, "users.Get", cacheKey)
+	data, ok, err := u.db.Get(ctx, cacheKey)
 	if err != nil {
-		return err
+		metrics.CacheRequests.WithLabelValues("users", errorResult(err)).Inc()
+		endSpan(span, err)
+		return nil, err
 	}
+	if !ok {
+		metrics.CacheRequests.WithLabelValues("users", "miss").Inc()
+		span.SetAttributes(attribute.Bool("cache.hit", false))
+		endSpan(span, nil)
+		return nil, nil
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", true))
+	endSpan(span, nil)
+
+	if len(data) == 0 {
+		metrics.CacheRequests.WithLabelValues("users", "negative").Inc()
+		return nil, store.ErrNotFound
+	}
+	metrics.CacheRequests.WithLabelValues("users", "hit").Inc()
+
+	var user store.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (u *UserStore) set(ctx context.Context, cacheKey string, data []byte, ttl time.Duration) {
+	ctx, span := startSpan(ctx, u.db, "users.Set", cacheKey)
+	err := u.db.Set(ctx, cacheKey, data, ttl)
+	endSpan(span, err)
+	if err != nil && !errors.Is(err, ErrUnavailable) {
+		logger.FromContext(ctx).Warn("error writing user cache", "key", cacheKey, "error", err.Error())
+	}
+}
 
-	return u.db.SetEX(ctx, cacheKey, json, UserExpTime).Err()
+func userGenerationKey(userID int64) string {
+	return fmt.Sprintf("user-%d-gen", userID)
 }
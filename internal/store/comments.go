@@ -16,11 +16,12 @@ type Comment struct {
 }
 
 type CommentStore struct {
-	db *sql.DB
+	db       *sql.DB
+	replicas *Replicas
 }
 
 func (c *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Comment, error) {
-	query := `SELECT c.id, c.user_id, c.post_id, c.content, c.created_at, users.id, users.username FROM commnets c
+	query := `SELECT c.id, c.user_id, c.post_id, c.content, c.created_at, users.id, users.username FROM comments c
 			  JOIN users ON c.user_id = users.id
 			  WHERE c.post_id = $1 ORDER BY c.created_at DESC`
 
@@ -28,7 +29,7 @@ func (c *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Commen
 	defer cancel()
 
 	var comments []*Comment
-	rows, err := c.db.QueryContext(ctx, query, postID)
+	rows, err := c.replicas.reader(ctx).QueryContext(ctx, query, postID)
 	if err != nil {
 		return comments, err
 	}
@@ -65,7 +66,7 @@ func (c *CommentStore) DeleteCommentsByPostID(ctx context.Context, postID int64)
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := c.db.ExecContext(ctx, query, postID)
+	_, err := conn(ctx, c.db).ExecContext(ctx, query, postID)
 	if err != nil {
 		return err
 	}
@@ -74,15 +75,45 @@ func (c *CommentStore) DeleteCommentsByPostID(ctx context.Context, postID int64)
 
 func (c *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	stmt := `INSERT INTO comments (user_id, post_id, content)
-			 VALUES ($1, $2, $3)`
+			 VALUES ($1, $2, $3) RETURNING id, created_at`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := c.db.ExecContext(ctx, stmt, comment.UserID, comment.PostID, comment.Content)
+	return conn(ctx, c.db).QueryRowContext(ctx, stmt, comment.UserID, comment.PostID, comment.Content).Scan(
+		&comment.ID,
+		&comment.CreatedAt,
+	)
+}
+
+func (c *CommentStore) GetByUserID(ctx context.Context, userID int64) ([]*Comment, error) {
+	query := `SELECT id, user_id, post_id, content, created_at FROM comments
+			  WHERE user_id = $1 ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, c.db).QueryContext(ctx, query, userID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	var comments []*Comment
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(
+			&c.ID,
+			&c.UserID,
+			&c.PostID,
+			&c.Content,
+			&c.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, &c)
+	}
+
+	return comments, rows.Err()
 }
@@ -23,7 +23,7 @@ func (f *FollowerStore) Follow(ctx context.Context, followerID int64, userID int
 	stmt := `INSERT INTO followers (user_id, follower_id)
 			 VALUES ($1, $2)`
 
-	res, err := f.db.ExecContext(ctx, stmt, userID, followerID)
+	res, err := conn(ctx, f.db).ExecContext(ctx, stmt, userID, followerID)
 	if err != nil {
 		return err
 	}
@@ -32,6 +32,7 @@ func (f *FollowerStore) Follow(ctx context.Context, followerID int64, userID int
 	if err != nil {
 		return err
 	}
+	setRowsAffected(ctx, row)
 
 	if row == 0 {
 		return err
@@ -46,7 +47,7 @@ func (f *FollowerStore) UnFollow(ctx context.Context, unfollowedID int64, userID
 
 	stmt := `DELETE FROM followers WHERE user_id = $1 AND follower_id = $2`
 
-	res, err := f.db.ExecContext(ctx, stmt, userID, unfollowedID)
+	res, err := conn(ctx, f.db).ExecContext(ctx, stmt, userID, unfollowedID)
 	if err != nil {
 		return err
 	}
@@ -55,6 +56,7 @@ func (f *FollowerStore) UnFollow(ctx context.Context, unfollowedID int64, userID
 	if err != nil {
 		return err
 	}
+	setRowsAffected(ctx, row)
 
 	if row == 0 {
 		return err
@@ -62,3 +64,42 @@ func (f *FollowerStore) UnFollow(ctx context.Context, unfollowedID int64, userID
 
 	return nil
 }
+
+// GetFollowers returns the users following userID.
+func (f *FollowerStore) GetFollowers(ctx context.Context, userID int64) ([]Follower, error) {
+	query := `SELECT user_id, follower_id, create_at FROM followers WHERE user_id = $1`
+	return f.list(ctx, query, userID)
+}
+
+// GetFollowing returns the users userID follows.
+func (f *FollowerStore) GetFollowing(ctx context.Context, userID int64) ([]Follower, error) {
+	query := `SELECT user_id, follower_id, create_at FROM followers WHERE follower_id = $1`
+	return f.list(ctx, query, userID)
+}
+
+func (f *FollowerStore) list(ctx context.Context, query string, userID int64) ([]Follower, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, f.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var followers []Follower
+	for rows.Next() {
+		var follower Follower
+		err := rows.Scan(
+			&follower.UserID,
+			&follower.FollowerID,
+			&follower.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		followers = append(followers, follower)
+	}
+
+	return followers, rows.Err()
+}
@@ -0,0 +1,456 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryDB holds the tables of the in-memory storage. A single lock guards
+// all of them, so operations spanning tables stay atomic like the
+// transactions of the Postgres stores.
+type memoryDB struct {
+	mu sync.RWMutex
+	// txMu runs the transactions one at a time.
+	txMu sync.Mutex
+
+	users        map[int64]*memoryUser
+	invitations  map[string]memoryToken
+	emailChanges map[string]memoryToken
+	posts        map[int64]*Post
+	comments     map[int64]*Comment
+	followers    []Follower
+	roles        []*Role
+	permissions  map[int64][]string
+	auditLog     []*memoryAuditEntry
+	webhooks     map[int64]*Webhook
+	deliveries   map[int64]*WebhookDelivery
+
+	lastID map[string]int64
+
+	// undo is the undo log of the transaction holding the write lock, if
+	// any.
+	undo *memoryTx
+}
+
+type memoryUser struct {
+	User
+	deletedAt time.Time
+}
+
+// memoryToken is a pending invitation or email change.
+type memoryToken struct {
+	userID int64
+	email  string
+	expiry time.Time
+}
+
+type memoryAuditEntry struct {
+	AuditEntry
+	createdAt time.Time
+}
+
+// NewMemoryStorage returns a Storage keeping everything in memory with the
+// same semantics and errors as the Postgres stores. Roles and permissions
+// are seeded like the migrations do. It is safe for concurrent use.
+func NewMemoryStorage() Storage {
+	db := &memoryDB{
+		users:        make(map[int64]*memoryUser),
+		invitations:  make(map[string]memoryToken),
+		emailChanges: make(map[string]memoryToken),
+		posts:        make(map[int64]*Post),
+		comments:     make(map[int64]*Comment),
+		webhooks:     make(map[int64]*Webhook),
+		deliveries:   make(map[int64]*WebhookDelivery),
+		lastID:       make(map[string]int64),
+		roles: []*Role{
+			{ID: 1, Name: "user", Level: 1, Description: "a user can create posts and comments"},
+			{ID: 2, Name: "moderator", Level: 2, Description: "a moderator can update other users posts"},
+			{ID: 3, Name: "admin", Level: 3, Description: "a admin can update and delete other users posts"},
+		},
+		permissions: map[int64][]string{
+			2: {"posts:update:any"},
+			3: {
+				"posts:update:any", "posts:delete:any", "users:ban",
+				"roles:manage", "audit:read", "webhooks:global",
+			},
+		},
+	}
+
+	return Storage{
+		Posts:     &memoryPostStore{db},
+		Users:     &memoryUserStore{db},
+		Comments:  &memoryCommentStore{db},
+		Followers: &memoryFollowerStore{db},
+		Roles:     &memoryRoleStore{db},
+		AuditLogs: &memoryAuditLogStore{db},
+		Webhooks:  &memoryWebhookStore{db},
+		Tx:        &memoryTxStore{db},
+	}
+}
+
+// nextID works like a BIGSERIAL sequence of table.
+func (db *memoryDB) nextID(table string) int64 {
+	db.lastID[table]++
+	return db.lastID[table]
+}
+
+// user returns the user with userID unless it does not exist or is soft deleted.
+func (db *memoryDB) user(userID int64) (*memoryUser, bool) {
+	u, ok := db.users[userID]
+	if !ok || !u.deletedAt.IsZero() {
+		return nil, false
+	}
+	return u, true
+}
+
+func (db *memoryDB) role(roleID int64) (*Role, bool) {
+	for _, r := range db.roles {
+		if r.ID == roleID {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// memoryNow formats the current time like a TIMESTAMP(0) column read into a string.
+func memoryNow() string {
+	return formatTimestamp(time.Now())
+}
+
+func formatTimestamp(t time.Time) string {
+	return t.Truncate(time.Second).Format(time.RFC3339Nano)
+}
+
+// page returns the bounds of the LIMIT and OFFSET window over n rows.
+func page(n, limit, offset int) (int, int) {
+	start := min(max(offset, 0), n)
+	end := min(start+max(limit, 0), n)
+	return start, end
+}
+
+// hasFold reports whether s contains substr ignoring case, like ILIKE '%substr%'.
+func hasFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+type memoryTxContextKey struct{}
+
+type memoryTxStore struct {
+	db *memoryDB
+}
+
+// InTx runs the transactions one at a time and undoes the changes of fn
+// when it fails. Only the rows fn changed are restored, writes made
+// meanwhile outside InTx are kept, unless they changed the same rows: they
+// would have waited for the transaction in Postgres. Like Postgres
+// sequences, ids taken by a failed fn are not reused. The changes of fn are
+// seen before the commit by calls outside InTx.
+func (s *memoryTxStore) InTx(ctx context.Context, opts *sql.TxOptions, fn func(context.Context) error) error {
+	if ctx.Value(memoryTxContextKey{}) != nil {
+		return fn(ctx)
+	}
+
+	hooks, err := s.run(ctx, fn)
+	if err != nil {
+		return err
+	}
+
+	hooks.run(ctx)
+	return nil
+}
+
+func (s *memoryTxStore) run(ctx context.Context, fn func(context.Context) error) (*afterCommit, error) {
+	s.db.txMu.Lock()
+	defer s.db.txMu.Unlock()
+
+	tx := &memoryTx{}
+	txCtx, hooks := withAfterCommit(context.WithValue(ctx, memoryTxContextKey{}, tx))
+	if err := fn(txCtx); err != nil {
+		s.db.mu.Lock()
+		defer s.db.mu.Unlock()
+
+		tx.rollback(s.db)
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// lock takes the write lock of the tables. Inside a transaction the writes
+// save the rows they change to its undo log until it unlocks.
+func (db *memoryDB) lock(ctx context.Context) (unlock func()) {
+	db.mu.Lock()
+
+	db.undo, _ = ctx.Value(memoryTxContextKey{}).(*memoryTx)
+	return func() {
+		db.undo = nil
+		db.mu.Unlock()
+	}
+}
+
+type followerKey struct {
+	userID, followerID int64
+}
+
+func (f Follower) key() followerKey {
+	return followerKey{f.UserID, f.FollowerID}
+}
+
+func (e *memoryAuditEntry) key() int64 {
+	return e.ID
+}
+
+// memoryTx is the undo log of a transaction: every row it changed, as it
+// was before the transaction first did.
+type memoryTx struct {
+	users        undoLog[int64, *memoryUser]
+	invitations  undoLog[string, memoryToken]
+	emailChanges undoLog[string, memoryToken]
+	posts        undoLog[int64, *Post]
+	comments     undoLog[int64, *Comment]
+	followers    undoLog[followerKey, Follower]
+	auditLog     undoLog[int64, *memoryAuditEntry]
+	webhooks     undoLog[int64, *Webhook]
+	deliveries   undoLog[int64, *WebhookDelivery]
+}
+
+// The save methods keep a row before a write changes, inserts or deletes
+// it. They do nothing outside a transaction, db must be locked.
+
+func (db *memoryDB) saveUser(userID int64) {
+	if db.undo != nil {
+		saveRow(&db.undo.users, db.users, userID, copyRow)
+	}
+}
+
+func (db *memoryDB) saveInvitation(token string) {
+	if db.undo != nil {
+		saveRow(&db.undo.invitations, db.invitations, token, nil)
+	}
+}
+
+func (db *memoryDB) saveEmailChange(token string) {
+	if db.undo != nil {
+		saveRow(&db.undo.emailChanges, db.emailChanges, token, nil)
+	}
+}
+
+func (db *memoryDB) savePost(postID int64) {
+	if db.undo != nil {
+		saveRow(&db.undo.posts, db.posts, postID, copyRow)
+	}
+}
+
+func (db *memoryDB) saveComment(commentID int64) {
+	if db.undo != nil {
+		saveRow(&db.undo.comments, db.comments, commentID, copyRow)
+	}
+}
+
+// saveFollower keeps f, existed is false when the write inserts it.
+func (db *memoryDB) saveFollower(f Follower, existed bool) {
+	if db.undo != nil {
+		db.undo.followers.save(f.key(), undoRow[Follower]{row: f, existed: existed})
+	}
+}
+
+// saveAuditEntry keeps an entry being appended, entries are never changed
+// once they are.
+func (db *memoryDB) saveAuditEntry(entryID int64) {
+	if db.undo != nil {
+		db.undo.auditLog.save(entryID, undoRow[*memoryAuditEntry]{})
+	}
+}
+
+func (db *memoryDB) saveWebhook(webhookID int64) {
+	if db.undo != nil {
+		saveRow(&db.undo.webhooks, db.webhooks, webhookID, copyRow)
+	}
+}
+
+func (db *memoryDB) saveDelivery(deliveryID int64) {
+	if db.undo != nil {
+		saveRow(&db.undo.deliveries, db.deliveries, deliveryID, copyRow)
+	}
+}
+
+// rollback puts the saved rows back, db must be locked.
+func (tx *memoryTx) rollback(db *memoryDB) {
+	tx.users.restore(db.users)
+	tx.invitations.restore(db.invitations)
+	tx.emailChanges.restore(db.emailChanges)
+	tx.posts.restore(db.posts)
+	tx.comments.restore(db.comments)
+	db.followers = restoreSlice(db.followers, Follower.key, tx.followers)
+	db.auditLog = restoreSlice(db.auditLog, (*memoryAuditEntry).key, tx.auditLog)
+	tx.webhooks.restore(db.webhooks)
+	tx.deliveries.restore(db.deliveries)
+}
+
+// undoLog holds the rows of a table before a transaction changed them.
+type undoLog[K comparable, V any] map[K]undoRow[V]
+
+type undoRow[V any] struct {
+	row V
+	// existed is false for rows the transaction inserted.
+	existed bool
+}
+
+func (log *undoLog[K, V]) save(key K, row undoRow[V]) {
+	if *log == nil {
+		*log = make(undoLog[K, V])
+	}
+	// An earlier write of the transaction already saved the row.
+	if _, ok := (*log)[key]; !ok {
+		(*log)[key] = row
+	}
+}
+
+// saveRow saves the row of key in rows, or that it is missing. Rows updated
+// in place are copied by clone.
+func saveRow[K comparable, V any](log *undoLog[K, V], rows map[K]V, key K, clone func(V) V) {
+	row, ok := rows[key]
+	if ok && clone != nil {
+		row = clone(row)
+	}
+	log.save(key, undoRow[V]{row: row, existed: ok})
+}
+
+func (log undoLog[K, V]) restore(rows map[K]V) {
+	for key, row := range log {
+		if row.existed {
+			rows[key] = row.row
+		} else {
+			delete(rows, key)
+		}
+	}
+}
+
+// restoreSlice restores a table kept as a slice, keeping the order of the
+// rows that stay.
+func restoreSlice[K comparable, V any](rows []V, key func(V) K, log undoLog[K, V]) []V {
+	keyed := keyRows(rows, key)
+	log.restore(keyed)
+
+	restored := make([]V, 0, len(keyed))
+	for _, row := range rows {
+		if r, ok := keyed[key(row)]; ok {
+			restored = append(restored, r)
+			delete(keyed, key(row))
+		}
+	}
+	for _, r := range keyed {
+		restored = append(restored, r)
+	}
+	return restored
+}
+
+func keyRows[K comparable, V any](rows []V, key func(V) K) map[K]V {
+	keyed := make(map[K]V, len(rows))
+	for _, row := range rows {
+		keyed[key(row)] = row
+	}
+	return keyed
+}
+
+// copyRow copies a row updated in place.
+func copyRow[T any](row *T) *T {
+	r := *row
+	return &r
+}
+
+type memoryRoleStore struct {
+	db *memoryDB
+}
+
+func (s *memoryRoleStore) GetByName(ctx context.Context, roleName string) (*Role, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, r := range s.db.roles {
+		if r.Name == roleName {
+			role := *r
+			return &role, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryRoleStore) GetAll(ctx context.Context) ([]*Role, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	roles := make([]*Role, 0, len(s.db.roles))
+	for _, r := range s.db.roles {
+		role := *r
+		roles = append(roles, &role)
+	}
+
+	slices.SortFunc(roles, func(a, b *Role) int {
+		return cmp.Or(cmp.Compare(a.Level, b.Level), cmp.Compare(a.ID, b.ID))
+	})
+	return roles, nil
+}
+
+func (s *memoryRoleStore) GetPermissions(ctx context.Context) (map[int64][]string, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	permissions := make(map[int64][]string, len(s.db.permissions))
+	for roleID, names := range s.db.permissions {
+		permissions[roleID] = slices.Clone(names)
+	}
+	return permissions, nil
+}
+
+type memoryAuditLogStore struct {
+	db *memoryDB
+}
+
+func (s *memoryAuditLogStore) Create(ctx context.Context, entry *AuditEntry) error {
+	defer s.db.lock(ctx)()
+
+	now := time.Now().Truncate(time.Second)
+	entry.ID = s.db.nextID("audit_log")
+	entry.CreatedAt = formatTimestamp(now)
+
+	stored := *entry
+	stored.Before = slices.Clone(entry.Before)
+	stored.After = slices.Clone(entry.After)
+	s.db.saveAuditEntry(entry.ID)
+	s.db.auditLog = append(s.db.auditLog, &memoryAuditEntry{AuditEntry: stored, createdAt: now})
+	return nil
+}
+
+func (s *memoryAuditLogStore) List(ctx context.Context, q AuditLogQuery) ([]*AuditEntry, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	// Parse leaves an empty bound zero, like the NULLIF in the query.
+	since, _ := time.Parse(time.DateTime, q.Since)
+	until, _ := time.Parse(time.DateTime, q.Until)
+
+	var entries []*AuditEntry
+	// The log is appended in order, so newest first is reverse order.
+	for _, e := range slices.Backward(s.db.auditLog) {
+		switch {
+		case q.ActorID != 0 && e.ActorID != q.ActorID,
+			q.TargetType != "" && e.TargetType != q.TargetType,
+			q.TargetID != 0 && e.TargetID != q.TargetID,
+			q.Action != "" && e.Action != q.Action,
+			e.createdAt.Before(since),
+			!until.IsZero() && e.createdAt.After(until):
+			continue
+		}
+
+		entry := e.AuditEntry
+		entries = append(entries, &entry)
+	}
+
+	start, end := page(len(entries), q.Limit, q.Offset)
+	return entries[start:end:end], nil
+}
@@ -0,0 +1,275 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+type memoryPostStore struct {
+	db *memoryDB
+}
+
+func (s *memoryPostStore) Create(ctx context.Context, post *Post) error {
+	defer s.db.lock(ctx)()
+
+	if _, ok := s.db.users[post.UserID]; !ok {
+		return ErrNotFound
+	}
+
+	post.ID = s.db.nextID("posts")
+	post.CreatedAt = memoryNow()
+	post.UpdatedAt = post.CreatedAt
+	post.Version = 0
+
+	s.db.savePost(post.ID)
+	s.db.posts[post.ID] = &Post{
+		ID:        post.ID,
+		Content:   post.Content,
+		Title:     post.Title,
+		UserID:    post.UserID,
+		Tags:      slices.Clone(post.Tags),
+		CreatedAt: post.CreatedAt,
+		UpdatedAt: post.UpdatedAt,
+	}
+	return nil
+}
+
+func (s *memoryPostStore) GetPostByID(ctx context.Context, postID int) (*Post, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	p, ok := s.db.posts[int64(postID)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyPost(p), nil
+}
+
+func (s *memoryPostStore) DeletePostByID(ctx context.Context, postID int64, version int) error {
+	defer s.db.lock(ctx)()
+
+	p, ok := s.db.posts[postID]
+	if !ok || p.Version != version {
+		return ErrConflict
+	}
+
+	s.db.savePost(postID)
+	delete(s.db.posts, postID)
+	return nil
+}
+
+func (s *memoryPostStore) UpdatePost(ctx context.Context, post *Post) error {
+	defer s.db.lock(ctx)()
+
+	p, ok := s.db.posts[post.ID]
+	if !ok || p.Version != post.Version {
+		return ErrConflict
+	}
+
+	s.db.savePost(post.ID)
+	p.Content = post.Content
+	p.Title = post.Title
+	p.Version++
+	post.Version = p.Version
+	return nil
+}
+
+// GetUserFeed follows the Postgres query: posts of userID and of the users
+// in its followers rows, only when it has any.
+func (s *memoryPostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	authors := make(map[int64]bool)
+	for _, f := range s.db.followers {
+		if f.UserID == userID {
+			authors[userID] = true
+			authors[f.FollowerID] = true
+		}
+	}
+
+	comments := make(map[int64]int)
+	for _, c := range s.db.comments {
+		comments[c.PostID]++
+	}
+
+	var feed []PostWithMetadata
+	for _, p := range s.db.posts {
+		author, ok := s.db.user(p.UserID)
+		if !authors[p.UserID] || !ok {
+			continue
+		}
+		if !hasFold(p.Title, fq.Search) && !hasFold(p.Content, fq.Search) {
+			continue
+		}
+
+		post := copyPost(p)
+		post.UpdatedAt = ""
+		post.User.Username = author.Username
+		feed = append(feed, PostWithMetadata{Post: *post, CommentCount: comments[p.ID]})
+	}
+
+	slices.SortFunc(feed, func(a, b PostWithMetadata) int {
+		c := cmp.Or(cmp.Compare(a.Post.CreatedAt, b.Post.CreatedAt), cmp.Compare(a.Post.ID, b.Post.ID))
+		if fq.Sort == "desc" {
+			return -c
+		}
+		return c
+	})
+
+	start, end := page(len(feed), fq.Limit, fq.Offset)
+	return feed[start:end:end], nil
+}
+
+func (s *memoryPostStore) GetByUserID(ctx context.Context, userID int64) ([]*Post, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var posts []*Post
+	for _, p := range s.db.posts {
+		if p.UserID == userID {
+			posts = append(posts, copyPost(p))
+		}
+	}
+
+	slices.SortFunc(posts, func(a, b *Post) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+	return posts, nil
+}
+
+func copyPost(p *Post) *Post {
+	post := *p
+	post.Tags = slices.Clone(p.Tags)
+	post.Comments = nil
+	return &post
+}
+
+type memoryCommentStore struct {
+	db *memoryDB
+}
+
+func (s *memoryCommentStore) GetByPostID(ctx context.Context, postID int64) ([]*Comment, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var comments []*Comment
+	for _, c := range s.db.comments {
+		u, ok := s.db.users[c.UserID]
+		if c.PostID != postID || !ok {
+			continue
+		}
+
+		comment := *c
+		comment.User = User{ID: u.ID, Username: u.Username}
+		comments = append(comments, &comment)
+	}
+
+	sortComments(comments)
+	return comments, nil
+}
+
+func (s *memoryCommentStore) Create(ctx context.Context, comment *Comment) error {
+	defer s.db.lock(ctx)()
+
+	comment.ID = s.db.nextID("comments")
+	comment.CreatedAt = memoryNow()
+
+	s.db.saveComment(comment.ID)
+	s.db.comments[comment.ID] = &Comment{
+		ID:        comment.ID,
+		UserID:    comment.UserID,
+		PostID:    comment.PostID,
+		Content:   comment.Content,
+		CreatedAt: comment.CreatedAt,
+	}
+	return nil
+}
+
+func (s *memoryCommentStore) GetByUserID(ctx context.Context, userID int64) ([]*Comment, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var comments []*Comment
+	for _, c := range s.db.comments {
+		if c.UserID == userID {
+			comment := *c
+			comments = append(comments, &comment)
+		}
+	}
+
+	sortComments(comments)
+	return comments, nil
+}
+
+// sortComments orders comments newest first.
+func sortComments(comments []*Comment) {
+	slices.SortFunc(comments, func(a, b *Comment) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+}
+
+type memoryFollowerStore struct {
+	db *memoryDB
+}
+
+func (s *memoryFollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+	defer s.db.lock(ctx)()
+
+	for _, id := range []int64{followerID, userID} {
+		if _, ok := s.db.users[id]; !ok {
+			return ErrNotFound
+		}
+	}
+
+	for _, f := range s.db.followers {
+		if f.UserID == userID && f.FollowerID == followerID {
+			return fmt.Errorf("user %d already follows user %d", followerID, userID)
+		}
+	}
+
+	f := Follower{
+		UserID:     userID,
+		FollowerID: followerID,
+		CreatedAt:  memoryNow(),
+	}
+	s.db.saveFollower(f, false)
+	s.db.followers = append(s.db.followers, f)
+	return nil
+}
+
+func (s *memoryFollowerStore) UnFollow(ctx context.Context, unfollowedID, userID int64) error {
+	defer s.db.lock(ctx)()
+
+	s.db.followers = slices.DeleteFunc(s.db.followers, func(f Follower) bool {
+		if f.UserID != userID || f.FollowerID != unfollowedID {
+			return false
+		}
+		s.db.saveFollower(f, true)
+		return true
+	})
+	return nil
+}
+
+func (s *memoryFollowerStore) GetFollowers(ctx context.Context, userID int64) ([]Follower, error) {
+	return s.list(func(f Follower) bool { return f.UserID == userID })
+}
+
+func (s *memoryFollowerStore) GetFollowing(ctx context.Context, userID int64) ([]Follower, error) {
+	return s.list(func(f Follower) bool { return f.FollowerID == userID })
+}
+
+func (s *memoryFollowerStore) list(match func(Follower) bool) ([]Follower, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var followers []Follower
+	for _, f := range s.db.followers {
+		if match(f) {
+			followers = append(followers, f)
+		}
+	}
+	return followers, nil
+}
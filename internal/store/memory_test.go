@@ -0,0 +1,42 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/MohummedSoliman/social/internal/store/storetest"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storetest.Run(t, store.NewMemoryStorage())
+}
+
+// TestPostgresStorage needs a migrated database, set TEST_DB_ADDR to run it.
+func TestPostgresStorage(t *testing.T) {
+	_, db := openPostgres(t)
+	storetest.Run(t, store.NewStorage(db))
+}
+
+// openPostgres connects a pgx pool to TEST_DB_ADDR and returns it with a
+// database/sql handle on it, or skips tb when it is not set.
+func openPostgres(tb testing.TB) (*pgxpool.Pool, *sql.DB) {
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		tb.Skip("TEST_DB_ADDR is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), addr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(pool.Close)
+
+	db := stdlib.OpenDBFromPool(pool)
+	tb.Cleanup(func() { db.Close() })
+	return pool, db
+}
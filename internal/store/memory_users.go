@@ -0,0 +1,290 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+)
+
+type memoryUserStore struct {
+	db *memoryDB
+}
+
+// Create keeps a non zero user.ID unlike the Postgres store, which lets
+// tests seed users with known IDs.
+func (s *memoryUserStore) Create(ctx context.Context, user *User) error {
+	defer s.db.lock(ctx)()
+
+	return s.create(user)
+}
+
+func (s *memoryUserStore) create(user *User) error {
+	for _, u := range s.db.users {
+		switch {
+		case strings.EqualFold(u.Email, user.Email):
+			return ErrDuplicateEmail
+		case u.Username == user.Username:
+			return ErrDuplicateUsername
+		}
+	}
+
+	if user.ID == 0 {
+		user.ID = s.db.nextID("users")
+	}
+	s.db.lastID["users"] = max(s.db.lastID["users"], user.ID)
+	user.CreatedAt = memoryNow()
+
+	s.db.saveUser(user.ID)
+	s.db.users[user.ID] = &memoryUser{User: User{
+		ID:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Password:  password{hash: user.Password.hash},
+		CreatedAt: user.CreatedAt,
+		RoleID:    user.RoleID,
+	}}
+	return nil
+}
+
+func (s *memoryUserStore) GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	u, ok := s.db.user(userID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	role, ok := s.db.role(u.RoleID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	user := u.copy()
+	user.Role = *role
+	return user, nil
+}
+
+func (s *memoryUserStore) CreateAndInviate(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+	defer s.db.lock(ctx)()
+
+	if err := s.create(user); err != nil {
+		return err
+	}
+
+	s.db.saveInvitation(token)
+	s.db.invitations[token] = memoryToken{userID: user.ID, expiry: time.Now().Add(invitationExp)}
+	return nil
+}
+
+func (s *memoryUserStore) ActivateUser(ctx context.Context, token string) (*User, error) {
+	defer s.db.lock(ctx)()
+
+	u, err := s.fromToken(s.db.invitations, token)
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.saveUser(u.ID)
+	u.IsActive = true
+	s.deleteTokens(s.db.invitations, s.db.saveInvitation, u.ID)
+
+	return u.copy(), nil
+}
+
+// fromToken returns the user of a token that has not expired, token is
+// hashed like the stored ones.
+func (s *memoryUserStore) fromToken(tokens map[string]memoryToken, token string) (*memoryUser, error) {
+	hash := sha256.Sum256([]byte(token))
+	t, ok := tokens[hex.EncodeToString(hash[:])]
+	if !ok || !t.expiry.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	u, ok := s.db.users[t.userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+// deleteTokens deletes the tokens of userID, save saves each of them first.
+func (s *memoryUserStore) deleteTokens(tokens map[string]memoryToken, save func(string), userID int64) {
+	for token, t := range tokens {
+		if t.userID == userID {
+			save(token)
+			delete(tokens, token)
+		}
+	}
+}
+
+func (s *memoryUserStore) Delete(ctx context.Context, userID int64) error {
+	defer s.db.lock(ctx)()
+
+	if _, ok := s.db.users[userID]; !ok {
+		return ErrNotFound
+	}
+
+	s.db.saveUser(userID)
+	delete(s.db.users, userID)
+	s.deleteTokens(s.db.invitations, s.db.saveInvitation, userID)
+	s.deleteTokens(s.db.emailChanges, s.db.saveEmailChange, userID)
+	return nil
+}
+
+func (s *memoryUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, u := range s.db.users {
+		if strings.EqualFold(u.Email, email) && u.IsActive && u.deletedAt.IsZero() {
+			return u.copy(), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *memoryUserStore) RequestEmailChange(ctx context.Context, userID int64, email, token string, exp time.Duration) error {
+	defer s.db.lock(ctx)()
+
+	if _, ok := s.db.users[userID]; !ok {
+		return ErrNotFound
+	}
+
+	s.deleteTokens(s.db.emailChanges, s.db.saveEmailChange, userID)
+	s.db.saveEmailChange(token)
+	s.db.emailChanges[token] = memoryToken{userID: userID, email: email, expiry: time.Now().Add(exp)}
+	return nil
+}
+
+func (s *memoryUserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	defer s.db.lock(ctx)()
+
+	u, err := s.fromToken(s.db.emailChanges, token)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(token))
+	email := s.db.emailChanges[hex.EncodeToString(hash[:])].email
+	for _, other := range s.db.users {
+		if other.ID != u.ID && strings.EqualFold(other.Email, email) {
+			return nil, ErrDuplicateEmail
+		}
+	}
+
+	s.db.saveUser(u.ID)
+	u.Email = email
+	s.deleteTokens(s.db.emailChanges, s.db.saveEmailChange, u.ID)
+
+	return u.copy(), nil
+}
+
+func (s *memoryUserStore) SoftDelete(ctx context.Context, userID int64) error {
+	return s.update(ctx, userID, func(u *memoryUser) {
+		u.deletedAt = time.Now()
+	})
+}
+
+func (s *memoryUserStore) PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (Purged, error) {
+	defer s.db.lock(ctx)()
+
+	before := time.Now().Add(-gracePeriod)
+
+	expired := make(map[int64]bool)
+	for _, u := range s.db.users {
+		if !u.deletedAt.IsZero() && u.deletedAt.Before(before) {
+			expired[u.ID] = true
+		}
+	}
+
+	var purged Purged
+	affected := make(map[int64]bool)
+	for id, c := range s.db.comments {
+		if p, ok := s.db.posts[c.PostID]; expired[c.UserID] || ok && expired[p.UserID] {
+			affected[c.PostID] = true
+			s.db.saveComment(id)
+			delete(s.db.comments, id)
+		}
+	}
+	for id, p := range s.db.posts {
+		if expired[p.UserID] {
+			affected[id] = true
+			s.db.savePost(id)
+			delete(s.db.posts, id)
+		}
+	}
+	for id := range affected {
+		purged.PostIDs = append(purged.PostIDs, id)
+	}
+	s.db.followers = slices.DeleteFunc(s.db.followers, func(f Follower) bool {
+		if !expired[f.UserID] && !expired[f.FollowerID] {
+			return false
+		}
+		s.db.saveFollower(f, true)
+		return true
+	})
+	for id, w := range s.db.webhooks {
+		if expired[w.UserID] {
+			deleteWebhook(s.db, id)
+		}
+	}
+	for id := range expired {
+		s.deleteTokens(s.db.invitations, s.db.saveInvitation, id)
+		s.deleteTokens(s.db.emailChanges, s.db.saveEmailChange, id)
+		s.db.saveUser(id)
+		delete(s.db.users, id)
+	}
+
+	purged.Users = int64(len(expired))
+	return purged, nil
+}
+
+func (s *memoryUserStore) UpdateRole(ctx context.Context, userID, roleID int64) error {
+	return s.update(ctx, userID, func(u *memoryUser) {
+		u.RoleID = roleID
+	})
+}
+
+func (s *memoryUserStore) Suspend(ctx context.Context, userID int64, reason string, until *time.Time) error {
+	return s.update(ctx, userID, func(u *memoryUser) {
+		u.Suspension = &Suspension{
+			Reason:    reason,
+			CreatedAt: time.Now(),
+			Until:     until,
+		}
+	})
+}
+
+func (s *memoryUserStore) Unsuspend(ctx context.Context, userID int64) error {
+	return s.update(ctx, userID, func(u *memoryUser) {
+		u.Suspension = nil
+	})
+}
+
+// update applies fn to a user that is not soft deleted and reports
+// ErrNotFound otherwise.
+func (s *memoryUserStore) update(ctx context.Context, userID int64, fn func(*memoryUser)) error {
+	defer s.db.lock(ctx)()
+
+	u, ok := s.db.user(userID)
+	if !ok {
+		return ErrNotFound
+	}
+
+	s.db.saveUser(userID)
+	fn(u)
+	return nil
+}
+
+func (u *memoryUser) copy() *User {
+	user := u.User
+	if u.Suspension != nil {
+		suspension := *u.Suspension
+		user.Suspension = &suspension
+	}
+	return &user
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+)
+
+type memoryWebhookStore struct {
+	db *memoryDB
+}
+
+func (s *memoryWebhookStore) Create(ctx context.Context, webhook *Webhook) error {
+	defer s.db.lock(ctx)()
+
+	if _, ok := s.db.users[webhook.UserID]; !ok {
+		return ErrNotFound
+	}
+
+	webhook.ID = s.db.nextID("webhooks")
+	webhook.CreatedAt = memoryNow()
+
+	stored := *webhook
+	stored.Events = slices.Clone(webhook.Events)
+	s.db.saveWebhook(webhook.ID)
+	s.db.webhooks[webhook.ID] = &stored
+	return nil
+}
+
+func (s *memoryWebhookStore) GetByUserID(ctx context.Context, userID int64) ([]*Webhook, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var webhooks []*Webhook
+	for _, w := range s.db.webhooks {
+		if w.UserID != userID {
+			continue
+		}
+
+		webhook := *w
+		webhook.Secret = ""
+		webhook.Events = slices.Clone(w.Events)
+		webhooks = append(webhooks, &webhook)
+	}
+
+	slices.SortFunc(webhooks, func(a, b *Webhook) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return webhooks, nil
+}
+
+func (s *memoryWebhookStore) Delete(ctx context.Context, webhookID, userID int64) error {
+	defer s.db.lock(ctx)()
+
+	w, ok := s.db.webhooks[webhookID]
+	if !ok || w.UserID != userID {
+		return ErrNotFound
+	}
+
+	deleteWebhook(s.db, webhookID)
+	return nil
+}
+
+// deleteWebhook removes a webhook with its deliveries, like the cascading
+// foreign key.
+func deleteWebhook(db *memoryDB, webhookID int64) {
+	db.saveWebhook(webhookID)
+	delete(db.webhooks, webhookID)
+	for id, d := range db.deliveries {
+		if d.WebhookID == webhookID {
+			db.saveDelivery(id)
+			delete(db.deliveries, id)
+		}
+	}
+}
+
+func (s *memoryWebhookStore) Enqueue(ctx context.Context, event WebhookEvent) (int64, error) {
+	defer s.db.lock(ctx)()
+
+	var enqueued int64
+	now := time.Now().Truncate(time.Second)
+	for _, w := range s.db.webhooks {
+		if _, ok := s.db.user(w.UserID); !ok || !slices.Contains(w.Events, event.Type) || !s.visible(w, event) {
+			continue
+		}
+
+		d := &WebhookDelivery{
+			ID:            s.db.nextID("webhook_deliveries"),
+			WebhookID:     w.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       slices.Clone(event.Payload),
+			Status:        DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     formatTimestamp(now),
+			UpdatedAt:     formatTimestamp(now),
+		}
+		s.db.saveDelivery(d.ID)
+		s.db.deliveries[d.ID] = d
+		enqueued++
+	}
+
+	return enqueued, nil
+}
+
+// visible reports whether the owner of w may see event.
+func (s *memoryWebhookStore) visible(w *Webhook, event WebhookEvent) bool {
+	if w.Global || w.UserID == event.ActorID || slices.Contains(event.Recipients, w.UserID) {
+		return true
+	}
+
+	return slices.ContainsFunc(s.db.followers, func(f Follower) bool {
+		return f.FollowerID == w.UserID && f.UserID == event.FollowersOf
+	})
+}
+
+func (s *memoryWebhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	defer s.db.lock(ctx)()
+
+	now := time.Now()
+
+	var due []*WebhookDelivery
+	for _, d := range s.db.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	slices.SortFunc(due, func(a, b *WebhookDelivery) int {
+		return cmp.Or(a.NextAttemptAt.Compare(b.NextAttemptAt), cmp.Compare(a.ID, b.ID))
+	})
+	_, end := page(len(due), limit, 0)
+
+	deliveries := make([]*WebhookDelivery, 0, end)
+	for _, d := range due[:end] {
+		s.db.saveDelivery(d.ID)
+		d.NextAttemptAt = now.Add(lease).Truncate(time.Second)
+
+		delivery := copyDelivery(d)
+		w := s.db.webhooks[d.WebhookID]
+		delivery.URL = w.URL
+		delivery.Secret = w.Secret
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (s *memoryWebhookStore) RecordAttempt(ctx context.Context, delivery *WebhookDelivery) error {
+	defer s.db.lock(ctx)()
+
+	d, ok := s.db.deliveries[delivery.ID]
+	if !ok {
+		return nil
+	}
+
+	s.db.saveDelivery(d.ID)
+	d.Status = delivery.Status
+	d.Attempts = delivery.Attempts
+	d.ResponseStatus = nil
+	if delivery.ResponseStatus != nil {
+		status := *delivery.ResponseStatus
+		d.ResponseStatus = &status
+	}
+	d.LastError = delivery.LastError
+	d.NextAttemptAt = delivery.NextAttemptAt.Truncate(time.Second)
+	d.UpdatedAt = memoryNow()
+	return nil
+}
+
+func (s *memoryWebhookStore) GetDeliveries(ctx context.Context, webhookID, userID int64, q WebhookDeliveryQuery) ([]*WebhookDelivery, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	w, ok := s.db.webhooks[webhookID]
+	if !ok || w.UserID != userID {
+		return nil, nil
+	}
+
+	var deliveries []*WebhookDelivery
+	for _, d := range s.db.deliveries {
+		if d.WebhookID == webhookID && (q.Status == "" || d.Status == q.Status) {
+			deliveries = append(deliveries, copyDelivery(d))
+		}
+	}
+
+	slices.SortFunc(deliveries, func(a, b *WebhookDelivery) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+
+	start, end := page(len(deliveries), q.Limit, q.Offset)
+	return deliveries[start:end:end], nil
+}
+
+func (s *memoryWebhookStore) Redeliver(ctx context.Context, deliveryID, webhookID, userID int64) error {
+	defer s.db.lock(ctx)()
+
+	d, ok := s.db.deliveries[deliveryID]
+	if !ok || d.WebhookID != webhookID {
+		return ErrNotFound
+	}
+	if w, ok := s.db.webhooks[webhookID]; !ok || w.UserID != userID {
+		return ErrNotFound
+	}
+
+	s.db.saveDelivery(d.ID)
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now().Truncate(time.Second)
+	d.UpdatedAt = memoryNow()
+	return nil
+}
+
+func copyDelivery(d *WebhookDelivery) *WebhookDelivery {
+	delivery := *d
+	delivery.Payload = slices.Clone(d.Payload)
+	if d.ResponseStatus != nil {
+		status := *d.ResponseStatus
+		delivery.ResponseStatus = &status
+	}
+	return &delivery
+}
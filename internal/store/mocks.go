@@ -2,32 +2,25 @@ package store
 
 import (
 	"context"
-	"database/sql"
 	"time"
 )
 
-func NewMockStore() Storage {
-	return Storage{
-		Users: &MockUserStore{},
-	}
-}
-
 type MockUserStore struct{}
 
-func (m *MockUserStore) Create(ctx context.Context, tx *sql.Tx, u *User) error {
+func (m *MockUserStore) Create(ctx context.Context, u *User) error {
 	return nil
 }
 
 func (m *MockUserStore) GetUserByID(ctx context.Context, id int64) (*User, error) {
-	return nil, nil
+	return &User{ID: id}, nil
 }
 
 func (m *MockUserStore) CreateAndInviate(ctx context.Context, u *User, token string, exp time.Duration) error {
 	return nil
 }
 
-func (m *MockUserStore) ActivateUser(ctx context.Context, token string) error {
-	return nil
+func (m *MockUserStore) ActivateUser(ctx context.Context, token string) (*User, error) {
+	return nil, nil
 }
 
 func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
@@ -37,3 +30,31 @@ func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 func (m *MockUserStore) GetByEmail(ctx context.Context, emil string) (*User, error) {
 	return nil, nil
 }
+
+func (m *MockUserStore) RequestEmailChange(ctx context.Context, userID int64, email, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	return nil, nil
+}
+
+func (m *MockUserStore) SoftDelete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *MockUserStore) PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (Purged, error) {
+	return Purged{}, nil
+}
+
+func (m *MockUserStore) UpdateRole(ctx context.Context, userID, roleID int64) error {
+	return nil
+}
+
+func (m *MockUserStore) Suspend(ctx context.Context, userID int64, reason string, until *time.Time) error {
+	return nil
+}
+
+func (m *MockUserStore) Unsuspend(ctx context.Context, userID int64) error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// errNotPgx is returned by withPgx for a *sql.DB not opened with
+// stdlib.OpenDBFromPool.
+var errNotPgx = errors.New("store: database does not use the pgx driver")
+
+// withPgx runs fn on the pgx connection under a connection of db, or of
+// the transaction of ctx, for the pgx APIs database/sql does not have.
+func withPgx(ctx context.Context, db *sql.DB, fn func(*pgx.Conn) error) error {
+	raw := func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errNotPgx
+		}
+		return fn(c.Conn())
+	}
+
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.conn.Raw(raw)
+	}
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.Raw(raw)
+}
+
+// typeMaps are reused by array, a pgtype.Map is not safe for concurrent use.
+var typeMaps = sync.Pool{
+	New: func() any { return pgtype.NewMap() },
+}
+
+type arrayScanner struct {
+	v any
+}
+
+// array scans a Postgres array into the slice v points to. Slices are
+// passed as query arguments as they are.
+func array(v any) sql.Scanner {
+	return arrayScanner{v}
+}
+
+func (a arrayScanner) Scan(src any) error {
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	return m.SQLScanner(a.v).Scan(src)
+}
+
+// isUniqueViolation reports whether err breaks the unique constraint named constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
@@ -0,0 +1,158 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq"
+)
+
+// The benchmarks compare the pgx store with the lib/pq implementation it
+// replaced. They need a seeded database, run make seed with TEST_DB_ADDR
+// as DB_ADDR first.
+
+// legacyFeedQuery is the feed query of the lib/pq store, counting the
+// comments in the same query.
+const legacyFeedQuery = `SELECT p.id, p.title, p.content, p.user_id, p.tags, p.version, p.created_at, u.username,
+	COUNT(c.id) comments_count
+	FROM posts p LEFT JOIN comments c ON c.post_id = p.id
+	LEFT JOIN users u ON p.user_id = u.id
+	JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
+	WHERE f.user_id = $1 AND u.deleted_at IS NULL AND
+		(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
+	GROUP BY p.id, u.username ORDER BY p.created_at desc
+	LIMIT $2 OFFSET $3`
+
+func openLegacy(b *testing.B) *sql.DB {
+	db, err := sql.Open("postgres", os.Getenv("TEST_DB_ADDR"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db
+}
+
+func BenchmarkFeed(b *testing.B) {
+	pool, db := openPostgres(b)
+	ctx := context.Background()
+
+	// the most followed user has the largest feed.
+	var userID int64
+	err := pool.QueryRow(ctx, `SELECT user_id FROM followers GROUP BY user_id ORDER BY COUNT(*) DESC LIMIT 1`).Scan(&userID)
+	if err != nil {
+		b.Skip("the database is not seeded: ", err)
+	}
+
+	fq := store.PaginatedFeedQuery{Limit: 20, Sort: "desc"}
+
+	b.Run("lib/pq join", func(b *testing.B) {
+		legacy := openLegacy(b)
+
+		for b.Loop() {
+			rows, err := legacy.QueryContext(ctx, legacyFeedQuery, userID, fq.Limit, fq.Offset, fq.Search)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for rows.Next() {
+				var meta store.PostWithMetadata
+				err := rows.Scan(
+					&meta.Post.ID,
+					&meta.Post.Title,
+					&meta.Post.Content,
+					&meta.Post.UserID,
+					pq.Array(&meta.Post.Tags),
+					&meta.Post.Version,
+					&meta.Post.CreatedAt,
+					&meta.Post.User.Username,
+					&meta.CommentCount,
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := rows.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pgx counted page", func(b *testing.B) {
+		posts := store.NewStorage(db).Posts
+
+		for b.Loop() {
+			if _, err := posts.GetUserFeed(ctx, userID, fq); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkCopyComments copies comments in transactions rolled back after
+// each batch, leaving the database as it was.
+func BenchmarkCopyComments(b *testing.B) {
+	pool, _ := openPostgres(b)
+	ctx := context.Background()
+
+	var postID, userID int64
+	err := pool.QueryRow(ctx, `SELECT id, user_id FROM posts LIMIT 1`).Scan(&postID, &userID)
+	if err != nil {
+		b.Skip("the database is not seeded: ", err)
+	}
+
+	columns := []string{"post_id", "user_id", "content"}
+
+	for _, n := range []int{100, 10000} {
+		rows := make([][]any, n)
+		for i := range rows {
+			rows[i] = []any{postID, userID, fmt.Sprintf("comment %d", i)}
+		}
+
+		b.Run(fmt.Sprintf("lib/pq CopyIn %d", n), func(b *testing.B) {
+			legacy := openLegacy(b)
+
+			for b.Loop() {
+				tx, err := legacy.BeginTx(ctx, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				stmt, err := tx.PrepareContext(ctx, pq.CopyIn("comments", columns...))
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, row := range rows {
+					if _, err := stmt.ExecContext(ctx, row...); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err := stmt.ExecContext(ctx); err != nil {
+					b.Fatal(err)
+				}
+
+				stmt.Close()
+				tx.Rollback()
+			}
+		})
+
+		b.Run(fmt.Sprintf("pgx CopyFrom %d", n), func(b *testing.B) {
+			for b.Loop() {
+				tx, err := pool.Begin(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if _, err := tx.CopyFrom(ctx, pgx.Identifier{"comments"}, columns, pgx.CopyFromRows(rows)); err != nil {
+					b.Fatal(err)
+				}
+
+				tx.Rollback(ctx)
+			}
+		})
+	}
+}
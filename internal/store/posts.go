@@ -4,9 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
-	_ "github.com/jackc/pgx/v5"
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
 )
 
 type Post struct {
@@ -28,7 +28,8 @@ type PostWithMetadata struct {
 }
 
 type PostStore struct {
-	db *sql.DB
+	db       *sql.DB
+	replicas *Replicas
 }
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
@@ -38,7 +39,7 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	row := s.db.QueryRowContext(ctx, query, post.Content, post.Title, post.UserID, pq.Array(post.Tags))
+	row := conn(ctx, s.db).QueryRowContext(ctx, query, post.Content, post.Title, post.UserID, post.Tags)
 	row.Scan(
 		&post.ID,
 		&post.CreatedAt,
@@ -60,12 +61,12 @@ func (s *PostStore) GetPostByID(ctx context.Context, postID int) (*Post, error)
 
 	var post Post
 
-	err := s.db.QueryRowContext(ctx, query, postID).Scan(
+	err := s.replicas.reader(ctx).QueryRowContext(ctx, query, postID).Scan(
 		&post.ID,
 		&post.Content,
 		&post.Title,
 		&post.UserID,
-		pq.Array(&post.Tags),
+		array(&post.Tags),
 		&post.CreatedAt,
 		&post.UpdatedAt,
 		&post.Version,
@@ -82,7 +83,9 @@ func (s *PostStore) GetPostByID(ctx context.Context, postID int) (*Post, error)
 	return &post, nil
 }
 
-func (s *PostStore) DeletePostByID(ctx context.Context, postID int64) error {
+// DeletePostByID deletes the post if it is still at version, so a change
+// made since it was read is not lost. Otherwise it returns ErrConflict.
+func (s *PostStore) DeletePostByID(ctx context.Context, postID int64, version int) error {
 	// tx, err := s.db.BeginTx(ctx, nil)
 	// if err != nil {
 	// 	return err
@@ -95,12 +98,12 @@ func (s *PostStore) DeletePostByID(ctx context.Context, postID int64) error {
 	// 	return err
 	// }
 
-	query := `DELETE FROM posts WHERE id = $1`
+	query := `DELETE FROM posts WHERE id = $1 AND version = $2`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res, err := s.db.ExecContext(ctx, query, postID)
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, postID, version)
 	if err != nil {
 		// tx.Rollback()
 		return err
@@ -110,9 +113,11 @@ func (s *PostStore) DeletePostByID(ctx context.Context, postID int64) error {
 	if err != nil {
 		return err
 	}
+	setRowsAffected(ctx, rows)
 
 	if rows == 0 {
-		return ErrNotFound
+		// The post was changed or deleted since it was read.
+		return ErrConflict
 	}
 
 	// if err := tx.Commit(); err != nil {
@@ -128,11 +133,12 @@ func (s *PostStore) UpdatePost(ctx context.Context, post *Post) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, post.Content, post.Title, post.ID, post.Version).Scan(&post.Version)
+	err := conn(ctx, s.db).QueryRowContext(ctx, query, post.Content, post.Title, post.ID, post.Version).Scan(&post.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
+			// The post was changed or deleted since it was read.
+			return ErrConflict
 		default:
 			return err
 		}
@@ -141,47 +147,102 @@ func (s *PostStore) UpdatePost(ctx context.Context, post *Post) error {
 	return nil
 }
 
+// feedPage selects the page of the feed of $1: its posts and the posts of
+// its followers, when it has any.
+func feedPage(sort string) string {
+	return `SELECT p.id, p.title, p.content, p.user_id, p.tags, p.version, p.created_at, u.username
+			FROM posts p JOIN users u ON u.id = p.user_id
+			WHERE u.deleted_at IS NULL AND
+				  EXISTS (SELECT 1 FROM followers f WHERE f.user_id = $1 AND (f.follower_id = p.user_id OR p.user_id = $1)) AND
+				  (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
+			ORDER BY p.created_at ` + sort + `, p.id ` + sort + `
+			LIMIT $2 OFFSET $3`
+}
+
+// GetUserFeed loads the page and the comment counts of its posts in one
+// query. The counts are subqueries over the page, so only the comments of
+// the posts returned are counted, in the same snapshot as the page.
 func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
-	query := `SELECT p.id, p.title, p.content, p.user_id, p.tags, p.version, p.created_at, u.username,
-	          COUNT(c.id) comments_count
-			  FROM posts p LEFT JOIN comments c ON c.post_id = p.id
-			  LEFT JOIN users u ON p.user_id = u.id
-			  JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
-			  WHERE f.user_id = $1 AND
-					(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
-			  GROUP BY p.id, u.username ORDER BY p.created_at ` + fq.Sort + `
-			  LIMIT $2 OFFSET $3`
+	query := `SELECT page.*, (SELECT COUNT(*) FROM comments c WHERE c.post_id = page.id)
+			  FROM (` + feedPage(fq.Sort) + `) page
+			  ORDER BY page.created_at ` + fq.Sort + `, page.id ` + fq.Sort
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID, fq.Limit, fq.Offset, fq.Search)
+	var postsWithMetaData []PostWithMetadata
+
+	err := s.replicas.withPgx(ctx, func(conn *pgx.Conn) error {
+		rows, err := conn.Query(ctx, query, userID, fq.Limit, fq.Offset, fq.Search)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				postMeta  PostWithMetadata
+				createdAt time.Time
+			)
+			err := rows.Scan(
+				&postMeta.Post.ID,
+				&postMeta.Post.Title,
+				&postMeta.Post.Content,
+				&postMeta.Post.UserID,
+				&postMeta.Post.Tags,
+				&postMeta.Post.Version,
+				&createdAt,
+				&postMeta.Post.User.Username,
+				&postMeta.CommentCount,
+			)
+			if err != nil {
+				return err
+			}
+
+			// formatted like database/sql formats a timestamp scanned into a string.
+			postMeta.Post.CreatedAt = createdAt.Format(time.RFC3339Nano)
+			postsWithMetaData = append(postsWithMetaData, postMeta)
+		}
+		return rows.Err()
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var postsWithMetaData []PostWithMetadata
+	return postsWithMetaData, nil
+}
 
+func (s *PostStore) GetByUserID(ctx context.Context, userID int64) ([]*Post, error) {
+	query := `SELECT id, content, title, user_id, tags, created_at, updated_at, version FROM posts
+			  WHERE user_id = $1 ORDER BY created_at DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*Post
 	for rows.Next() {
-		var postMeta PostWithMetadata
+		var post Post
 		err := rows.Scan(
-			&postMeta.Post.ID,
-			&postMeta.Post.Title,
-			&postMeta.Post.Content,
-			&postMeta.Post.UserID,
-			pq.Array(&postMeta.Post.Tags),
-			&postMeta.Post.Version,
-			&postMeta.Post.CreatedAt,
-			&postMeta.Post.User.Username,
-			&postMeta.CommentCount,
+			&post.ID,
+			&post.Content,
+			&post.Title,
+			&post.UserID,
+			array(&post.Tags),
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
 		)
 		if err != nil {
 			return nil, err
 		}
-
-		postsWithMetaData = append(postsWithMetaData, postMeta)
+		posts = append(posts, &post)
 	}
 
-	return postsWithMetaData, nil
+	return posts, rows.Err()
 }
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/metrics"
+	"github.com/jackc/pgx/v5"
+)
+
+type primaryContextKey struct{}
+
+// WithPrimary pins the reads made with ctx to the primary, so a request
+// reads its own writes and read-modify-write cycles see the latest rows.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryContextKey{}, true)
+}
+
+// PinnedToPrimary reports whether ctx was pinned with WithPrimary.
+func PinnedToPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryContextKey{}).(bool)
+	return pinned
+}
+
+// Replicas routes reads that can be served slightly stale to the healthy
+// read replicas in turn, and to the primary when none is healthy.
+type Replicas struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+type replica struct {
+	db      *sql.DB
+	name    string
+	healthy atomic.Bool
+}
+
+// NewReplicas starts with every replica healthy until Check or Run checks
+// them.
+func NewReplicas(primary *sql.DB, replicas ...*sql.DB) *Replicas {
+	r := &Replicas{primary: primary}
+	for i, db := range replicas {
+		rep := &replica{db: db, name: strconv.Itoa(i)}
+		rep.healthy.Store(true)
+		metrics.DBReplicaHealthy.WithLabelValues(rep.name).Set(1)
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+// reader returns the pool a read only query should run on.
+func (r *Replicas) reader(ctx context.Context) querier {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.Tx
+	}
+
+	if len(r.replicas) > 0 && !PinnedToPrimary(ctx) {
+		n := uint64(len(r.replicas))
+		start := r.next.Add(1)
+		for i := range n {
+			rep := r.replicas[(start+i)%n]
+			if rep.healthy.Load() {
+				metrics.DBReads.WithLabelValues("replica").Inc()
+				return rep.db
+			}
+		}
+	}
+
+	metrics.DBReads.WithLabelValues("primary").Inc()
+	return r.primary
+}
+
+// withPgx runs fn on a pgx connection of the pool reader picks, or of the
+// transaction of ctx.
+func (r *Replicas) withPgx(ctx context.Context, fn func(*pgx.Conn) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return withPgx(ctx, r.primary, fn)
+	}
+	return withPgx(ctx, r.reader(ctx).(*sql.DB), fn)
+}
+
+// Run pings the replicas every interval until ctx is done, taking the ones
+// that fail out of rotation until they answer again.
+func (r *Replicas) Run(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Check(ctx, interval, logger)
+		}
+	}
+}
+
+// Check pings every replica once, taking the ones that fail out of rotation
+// and bringing back the ones that answer.
+func (r *Replicas) Check(ctx context.Context, timeout time.Duration, logger *slog.Logger) {
+	for _, rep := range r.replicas {
+		r.check(ctx, rep, timeout, logger)
+	}
+}
+
+func (r *Replicas) check(ctx context.Context, rep *replica, timeout time.Duration, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := rep.db.PingContext(ctx)
+	healthy := err == nil
+	if rep.healthy.Swap(healthy) == healthy {
+		return
+	}
+
+	if healthy {
+		logger.Info("read replica is back in rotation", "replica", rep.name)
+		metrics.DBReplicaHealthy.WithLabelValues(rep.name).Set(1)
+	} else {
+		logger.Warn("read replica taken out of rotation", "replica", rep.name, "error", err.Error())
+		metrics.DBReplicaHealthy.WithLabelValues(rep.name).Set(0)
+	}
+}
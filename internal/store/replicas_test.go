@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestReplicas(t *testing.T) {
+	open := func() *sql.DB {
+		return openAddr(t, "postgres://localhost/social?sslmode=disable")
+	}
+
+	primary, a, b := open(), open(), open()
+	replicas := NewReplicas(primary, a, b)
+	ctx := context.Background()
+
+	t.Run("Should read from the replicas in turn", func(t *testing.T) {
+		first, second := replicas.reader(ctx), replicas.reader(ctx)
+		if first == second || first == primary || second == primary {
+			t.Error("Expected reads to alternate between the replicas")
+		}
+	})
+
+	t.Run("Should skip unhealthy replicas", func(t *testing.T) {
+		replicas.replicas[0].healthy.Store(false)
+		defer replicas.replicas[0].healthy.Store(true)
+
+		for range 3 {
+			if got := replicas.reader(ctx); got != b {
+				t.Fatal("Expected every read on the healthy replica")
+			}
+		}
+
+		replicas.replicas[1].healthy.Store(false)
+		defer replicas.replicas[1].healthy.Store(true)
+		if got := replicas.reader(ctx); got != primary {
+			t.Error("Expected the primary without healthy replicas")
+		}
+	})
+
+	t.Run("Should read from the primary when pinned", func(t *testing.T) {
+		if got := replicas.reader(WithPrimary(ctx)); got != primary {
+			t.Error("Expected the primary for a pinned context")
+		}
+	})
+
+	t.Run("Should take replicas that fail the check out of rotation", func(t *testing.T) {
+		down := openAddr(t, "postgres://127.0.0.1:1/social?sslmode=disable")
+		replicas := NewReplicas(primary, down)
+
+		replicas.Check(ctx, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if got := replicas.reader(ctx); got != primary {
+			t.Error("Expected the primary while the replica is down")
+		}
+	})
+}
+
+func openAddr(t *testing.T, addr string) *sql.DB {
+	db, err := sql.Open("pgx", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
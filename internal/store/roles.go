@@ -13,7 +13,8 @@ type Role struct {
 }
 
 type RoleStore struct {
-	db *sql.DB
+	db       *sql.DB
+	replicas *Replicas
 }
 
 func (r *RoleStore) GetByName(ctx context.Context, roleName string) (*Role, error) {
@@ -23,7 +24,7 @@ func (r *RoleStore) GetByName(ctx context.Context, roleName string) (*Role, erro
 	defer cancel()
 
 	var role Role
-	row := r.db.QueryRowContext(ctx, query, roleName)
+	row := r.replicas.reader(ctx).QueryRowContext(ctx, query, roleName)
 	err := row.Scan(
 		&role.ID,
 		&role.Name,
@@ -41,3 +42,62 @@ func (r *RoleStore) GetByName(ctx context.Context, roleName string) (*Role, erro
 
 	return &role, nil
 }
+
+func (r *RoleStore) GetAll(ctx context.Context) ([]*Role, error) {
+	query := `SELECT id, name, level, description FROM roles ORDER BY level, id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := r.replicas.reader(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*Role
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(
+			&role.ID,
+			&role.Name,
+			&role.Level,
+			&role.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+
+	return roles, rows.Err()
+}
+
+// GetPermissions returns the permission names granted to every role keyed by role id.
+func (r *RoleStore) GetPermissions(ctx context.Context) (map[int64][]string, error) {
+	query := `SELECT rp.role_id, p.name FROM role_permissions rp
+			  JOIN permissions p ON rp.permission_id = p.id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := r.replicas.reader(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := make(map[int64][]string)
+	for rows.Next() {
+		var (
+			roleID int64
+			name   string
+		)
+		if err := rows.Scan(&roleID, &name); err != nil {
+			return nil, err
+		}
+		permissions[roleID] = append(permissions[roleID], name)
+	}
+
+	return permissions, rows.Err()
+}
@@ -6,10 +6,13 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/MohummedSoliman/social/internal/logger"
 )
 
 var (
 	ErrNotFound          = errors.New("record not found")
+	ErrConflict          = errors.New("edit conflict")
 	QueryTimeoutDuration = time.Second * 5
 )
 
@@ -19,59 +22,111 @@ type Storage struct {
 	Comments  Comments
 	Followers Followers
 	Roles     Roles
+	AuditLogs AuditLogs
+	Webhooks  Webhooks
+	Tx        Transactor
+}
+
+// Transactor composes store operations into one unit of work.
+type Transactor interface {
+	InTx(ctx context.Context, opts *sql.TxOptions, fn func(context.Context) error) error
 }
 
 type Posts interface {
 	Create(context.Context, *Post) error
 	GetPostByID(context.Context, int) (*Post, error)
-	DeletePostByID(context.Context, int64) error
+	DeletePostByID(context.Context, int64, int) error
 	UpdatePost(context.Context, *Post) error
 	GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+	GetByUserID(context.Context, int64) ([]*Post, error)
 }
 
 type Users interface {
-	Create(context.Context, *sql.Tx, *User) error
+	Create(context.Context, *User) error
 	GetUserByID(context.Context, int64) (*User, error)
 	CreateAndInviate(context.Context, *User, string, time.Duration) error
-	ActivateUser(ctx context.Context, token string) error
+	ActivateUser(ctx context.Context, token string) (*User, error)
 	Delete(context.Context, int64) error
 	GetByEmail(context.Context, string) (*User, error)
+	RequestEmailChange(context.Context, int64, string, string, time.Duration) error
+	ConfirmEmailChange(context.Context, string) (*User, error)
+	SoftDelete(context.Context, int64) error
+	PurgeDeleted(context.Context, time.Duration) (Purged, error)
+	UpdateRole(ctx context.Context, userID, roleID int64) error
+	Suspend(ctx context.Context, userID int64, reason string, until *time.Time) error
+	Unsuspend(context.Context, int64) error
 }
 
 type Comments interface {
 	GetByPostID(context.Context, int64) ([]*Comment, error)
 	Create(context.Context, *Comment) error
+	GetByUserID(context.Context, int64) ([]*Comment, error)
 }
 
 type Followers interface {
 	Follow(ctx context.Context, followerID, userID int64) error
 	UnFollow(ctx context.Context, unfollowedID, userID int64) error
+	GetFollowers(ctx context.Context, userID int64) ([]Follower, error)
+	GetFollowing(ctx context.Context, userID int64) ([]Follower, error)
 }
 
 type Roles interface {
 	GetByName(context.Context, string) (*Role, error)
+	GetAll(context.Context) ([]*Role, error)
+	GetPermissions(context.Context) (map[int64][]string, error)
+}
+
+type AuditLogs interface {
+	Create(context.Context, *AuditEntry) error
+	List(context.Context, AuditLogQuery) ([]*AuditEntry, error)
+}
+
+type Webhooks interface {
+	Create(context.Context, *Webhook) error
+	GetByUserID(context.Context, int64) ([]*Webhook, error)
+	Delete(ctx context.Context, webhookID, userID int64) error
+	Enqueue(context.Context, WebhookEvent) (int64, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error)
+	RecordAttempt(context.Context, *WebhookDelivery) error
+	GetDeliveries(ctx context.Context, webhookID, userID int64, q WebhookDeliveryQuery) ([]*WebhookDelivery, error)
+	Redeliver(ctx context.Context, deliveryID, webhookID, userID int64) error
 }
 
 func NewStorage(db *sql.DB) Storage {
+	return NewReplicatedStorage(NewReplicas(db))
+}
+
+// NewReplicatedStorage writes to the primary of replicas and serves the
+// feed, posts, comments and roles from its read replicas.
+func NewReplicatedStorage(replicas *Replicas) Storage {
+	db := replicas.primary
 	return Storage{
-		Posts:     &PostStore{db},
+		Posts:     &PostStore{db: db, replicas: replicas},
 		Users:     &UserStore{db},
-		Comments:  &CommentStore{db},
+		Comments:  &CommentStore{db: db, replicas: replicas},
 		Followers: &FollowerStore{db},
-		Roles:     &RoleStore{db},
+		Roles:     &RoleStore{db: db, replicas: replicas},
+		AuditLogs: &AuditLogStore{db},
+		Webhooks:  &WebhookStore{db},
+		Tx:        &TxStore{db},
 	}
 }
 
+// WithTransaction runs fn in a transaction, or in the one of ctx when
+// called inside Transactor.InTx.
 func WithTransaction(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
-	tx, err := db.Begin()
+	if tx, ok := txFromContext(ctx); ok {
+		return fn(tx.Tx)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	if err := fn(tx); err != nil {
-		err := tx.Rollback()
-		if err != nil {
-			return err
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logger.FromContext(ctx).Error("error rolling back transaction", "error", rbErr.Error())
 		}
 		return err
 	}
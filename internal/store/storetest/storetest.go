@@ -0,0 +1,697 @@
+// Package storetest is a conformance suite for store.Storage
+// implementations, it checks the behaviour and errors the handlers rely on.
+package storetest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/google/uuid"
+)
+
+// Run runs the suite against s. Data is created with unique names, so s
+// may be a database shared with other runs.
+func Run(t *testing.T, s store.Storage) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, s) })
+	t.Run("Posts", func(t *testing.T) { testPosts(t, s) })
+	t.Run("Comments", func(t *testing.T) { testComments(t, s) })
+	t.Run("Followers", func(t *testing.T) { testFollowers(t, s) })
+	t.Run("Roles", func(t *testing.T) { testRoles(t, s) })
+	t.Run("AuditLogs", func(t *testing.T) { testAuditLogs(t, s) })
+	t.Run("Webhooks", func(t *testing.T) { testWebhooks(t, s) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, s) })
+	t.Run("Transactions", func(t *testing.T) { testTransactions(t, s) })
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// newUser creates an activated user.
+func newUser(t *testing.T, s store.Storage) *store.User {
+	t.Helper()
+	ctx := context.Background()
+
+	user, token := invitedUser(t, s)
+	if _, err := s.Users.ActivateUser(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// invitedUser creates a user and returns it with its activation token.
+func invitedUser(t *testing.T, s store.Storage) (*store.User, string) {
+	t.Helper()
+
+	name := "test-" + uuid.New().String()
+	user := &store.User{
+		Username: name,
+		Email:    name + "@example.com",
+		RoleID:   1,
+	}
+	if err := user.Password.Set("password"); err != nil {
+		t.Fatal(err)
+	}
+
+	token := uuid.New().String()
+	if err := s.Users.CreateAndInviate(context.Background(), user, hashToken(token), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	return user, token
+}
+
+func newPost(t *testing.T, s store.Storage, userID int64) *store.Post {
+	t.Helper()
+
+	post := &store.Post{
+		Title:   "title",
+		Content: "content",
+		UserID:  userID,
+		Tags:    []string{"go"},
+	}
+	if err := s.Posts.Create(context.Background(), post); err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func expectErr(t *testing.T, got, want error) {
+	t.Helper()
+	if !errors.Is(got, want) {
+		t.Errorf("Expected error %v, but got %v", want, got)
+	}
+}
+
+func testUsers(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+
+	t.Run("Should activate invited users", func(t *testing.T) {
+		user, token := invitedUser(t, s)
+
+		_, err := s.Users.GetByEmail(ctx, user.Email)
+		expectErr(t, err, store.ErrNotFound)
+
+		_, err = s.Users.ActivateUser(ctx, "invalid")
+		expectErr(t, err, store.ErrNotFound)
+
+		if _, err := s.Users.ActivateUser(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Users.GetByEmail(ctx, strings.ToUpper(user.Email))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != user.ID || !got.IsActive {
+			t.Errorf("Expected active user %d, but got %+v", user.ID, got)
+		}
+		if err := got.Password.Compare("password"); err != nil {
+			t.Error("Expected the password hash to be loaded")
+		}
+
+		_, err = s.Users.ActivateUser(ctx, token)
+		expectErr(t, err, store.ErrNotFound)
+	})
+
+	t.Run("Should load users with their role", func(t *testing.T) {
+		user := newUser(t, s)
+
+		got, err := s.Users.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Username != user.Username || got.Role.Name != "user" {
+			t.Errorf("Expected %s with role user, but got %+v", user.Username, got)
+		}
+
+		_, err = s.Users.GetUserByID(ctx, -1)
+		expectErr(t, err, store.ErrNotFound)
+	})
+
+	t.Run("Should reject duplicate emails and usernames", func(t *testing.T) {
+		user := newUser(t, s)
+
+		dup := &store.User{Username: "test-" + uuid.New().String(), Email: strings.ToUpper(user.Email), RoleID: 1}
+		err := s.Users.CreateAndInviate(ctx, dup, hashToken(uuid.New().String()), time.Hour)
+		expectErr(t, err, store.ErrDuplicateEmail)
+
+		dup = &store.User{Username: user.Username, Email: uuid.New().String() + "@example.com", RoleID: 1}
+		err = s.Users.CreateAndInviate(ctx, dup, hashToken(uuid.New().String()), time.Hour)
+		expectErr(t, err, store.ErrDuplicateUsername)
+	})
+
+	t.Run("Should change emails once confirmed", func(t *testing.T) {
+		user := newUser(t, s)
+		other := newUser(t, s)
+
+		email := "changed-" + user.Email
+		token := uuid.New().String()
+		if err := s.Users.RequestEmailChange(ctx, user.ID, email, hashToken(token), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Users.ConfirmEmailChange(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != email {
+			t.Errorf("Expected email %s, but got %s", email, got.Email)
+		}
+
+		_, err = s.Users.ConfirmEmailChange(ctx, token)
+		expectErr(t, err, store.ErrNotFound)
+
+		token = uuid.New().String()
+		if err := s.Users.RequestEmailChange(ctx, user.ID, other.Email, hashToken(token), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Users.ConfirmEmailChange(ctx, token)
+		expectErr(t, err, store.ErrDuplicateEmail)
+	})
+
+	t.Run("Should suspend and change the role of users", func(t *testing.T) {
+		user := newUser(t, s)
+
+		admin, err := s.Roles.GetByName(ctx, "admin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Users.UpdateRole(ctx, user.ID, admin.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		until := time.Now().Add(time.Hour)
+		if err := s.Users.Suspend(ctx, user.ID, "spam", &until); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Users.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role.Name != "admin" || !got.IsSuspended() || got.Suspension.Reason != "spam" {
+			t.Errorf("Expected a suspended admin, but got %+v", got)
+		}
+
+		if err := s.Users.Unsuspend(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		got, err = s.Users.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.IsSuspended() {
+			t.Error("Expected the user not to be suspended")
+		}
+	})
+
+	t.Run("Should hide soft deleted users", func(t *testing.T) {
+		user := newUser(t, s)
+
+		if err := s.Users.SoftDelete(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := s.Users.GetUserByID(ctx, user.ID)
+		expectErr(t, err, store.ErrNotFound)
+		_, err = s.Users.GetByEmail(ctx, user.Email)
+		expectErr(t, err, store.ErrNotFound)
+
+		expectErr(t, s.Users.SoftDelete(ctx, user.ID), store.ErrNotFound)
+		expectErr(t, s.Users.UpdateRole(ctx, user.ID, 1), store.ErrNotFound)
+		expectErr(t, s.Users.Suspend(ctx, user.ID, "spam", nil), store.ErrNotFound)
+		expectErr(t, s.Users.Unsuspend(ctx, user.ID), store.ErrNotFound)
+	})
+
+	t.Run("Should purge soft deleted users with their posts and comments", func(t *testing.T) {
+		user, other := newUser(t, s), newUser(t, s)
+		own, commented := newPost(t, s, user.ID), newPost(t, s, other.ID)
+		if err := s.Comments.Create(ctx, &store.Comment{PostID: commented.ID, UserID: user.ID, Content: "comment"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Users.SoftDelete(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		// a negative grace period purges the users deleted just now.
+		purged, err := s.Users.PurgeDeleted(ctx, -time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if purged.Users < 1 {
+			t.Errorf("Expected the deleted user to be purged, but got %d users", purged.Users)
+		}
+		for _, post := range []*store.Post{own, commented} {
+			if !slices.Contains(purged.PostIDs, post.ID) {
+				t.Errorf("Expected post %d in the affected posts %v", post.ID, purged.PostIDs)
+			}
+		}
+
+		_, err = s.Posts.GetPostByID(ctx, int(own.ID))
+		expectErr(t, err, store.ErrNotFound)
+		comments, err := s.Comments.GetByPostID(ctx, commented.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(comments) != 0 {
+			t.Errorf("Expected the comments of the purged user to be removed, but got %d", len(comments))
+		}
+	})
+
+	t.Run("Should delete users", func(t *testing.T) {
+		user, _ := invitedUser(t, s)
+
+		if err := s.Users.Delete(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := s.Users.GetUserByID(ctx, user.ID)
+		expectErr(t, err, store.ErrNotFound)
+		expectErr(t, s.Users.Delete(ctx, user.ID), store.ErrNotFound)
+	})
+}
+
+func testPosts(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	user := newUser(t, s)
+
+	t.Run("Should update posts of the current version only", func(t *testing.T) {
+		post := newPost(t, s, user.ID)
+
+		got, err := s.Posts.GetPostByID(ctx, int(post.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != post.Title || got.UserID != user.ID || len(got.Tags) != 1 {
+			t.Errorf("Expected post %+v, but got %+v", post, got)
+		}
+
+		got.Title = "updated"
+		if err := s.Posts.UpdatePost(ctx, got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != post.Version+1 {
+			t.Errorf("Expected version %d, but got %d", post.Version+1, got.Version)
+		}
+
+		expectErr(t, s.Posts.UpdatePost(ctx, post), store.ErrConflict)
+	})
+
+	t.Run("Should delete posts", func(t *testing.T) {
+		post := newPost(t, s, user.ID)
+
+		if err := s.Posts.DeletePostByID(ctx, post.ID, post.Version); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := s.Posts.GetPostByID(ctx, int(post.ID))
+		expectErr(t, err, store.ErrNotFound)
+		expectErr(t, s.Posts.DeletePostByID(ctx, post.ID, post.Version), store.ErrConflict)
+		expectErr(t, s.Posts.UpdatePost(ctx, post), store.ErrConflict)
+	})
+
+	t.Run("Should not delete a post changed since it was read", func(t *testing.T) {
+		post := newPost(t, s, user.ID)
+		stale := post.Version
+
+		if err := s.Posts.UpdatePost(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+
+		expectErr(t, s.Posts.DeletePostByID(ctx, post.ID, stale), store.ErrConflict)
+		if _, err := s.Posts.GetPostByID(ctx, int(post.ID)); err != nil {
+			t.Errorf("Expected the post to be kept, but got %v", err)
+		}
+	})
+
+	t.Run("Should list the posts of a user", func(t *testing.T) {
+		author := newUser(t, s)
+		newPost(t, s, author.ID)
+		newPost(t, s, author.ID)
+
+		posts, err := s.Posts.GetByUserID(ctx, author.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) != 2 {
+			t.Errorf("Expected 2 posts, but got %d", len(posts))
+		}
+	})
+}
+
+func testComments(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	user := newUser(t, s)
+	post := newPost(t, s, user.ID)
+
+	comment := &store.Comment{UserID: user.ID, PostID: post.ID, Content: "comment"}
+	if err := s.Comments.Create(ctx, comment); err != nil {
+		t.Fatal(err)
+	}
+	if comment.ID == 0 || comment.CreatedAt == "" {
+		t.Errorf("Expected the id and creation time to be set, but got %+v", comment)
+	}
+
+	comments, err := s.Comments.GetByPostID(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].User.Username != user.Username {
+		t.Errorf("Expected the comment with its author, but got %+v", comments)
+	}
+
+	comments, err = s.Comments.GetByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].ID != comment.ID {
+		t.Errorf("Expected comment %d, but got %+v", comment.ID, comments)
+	}
+}
+
+func testFollowers(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	follower := newUser(t, s)
+	followed := newUser(t, s)
+
+	if err := s.Followers.Follow(ctx, follower.ID, followed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	followers, err := s.Followers.GetFollowers(ctx, followed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 1 || followers[0].FollowerID != follower.ID {
+		t.Errorf("Expected follower %d, but got %+v", follower.ID, followers)
+	}
+
+	following, err := s.Followers.GetFollowing(ctx, follower.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(following) != 1 || following[0].UserID != followed.ID {
+		t.Errorf("Expected to follow %d, but got %+v", followed.ID, following)
+	}
+
+	if err := s.Followers.UnFollow(ctx, follower.ID, followed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	followers, err = s.Followers.GetFollowers(ctx, followed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != 0 {
+		t.Errorf("Expected no followers after unfollowing, but got %+v", followers)
+	}
+}
+
+func testRoles(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+
+	admin, err := s.Roles.GetByName(ctx, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Roles.GetByName(ctx, "missing")
+	expectErr(t, err, store.ErrNotFound)
+
+	roles, err := s.Roles.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) < 3 || roles[len(roles)-1].Level < roles[0].Level {
+		t.Errorf("Expected the roles ordered by level, but got %+v", roles)
+	}
+
+	permissions, err := s.Roles.GetPermissions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(permissions[admin.ID], ","), "roles:manage") {
+		t.Errorf("Expected admin to manage roles, but got %v", permissions[admin.ID])
+	}
+}
+
+func testAuditLogs(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	actor := newUser(t, s)
+
+	for _, action := range []string{"user.login", "user.ban"} {
+		entry := &store.AuditEntry{
+			ActorID:    actor.ID,
+			Action:     action,
+			TargetType: "user",
+			TargetID:   actor.ID,
+			After:      json.RawMessage(`{"role":"admin"}`),
+		}
+		if err := s.AuditLogs.Create(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := s.AuditLogs.List(ctx, store.AuditLogQuery{Limit: 10, ActorID: actor.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "user.ban" {
+		t.Errorf("Expected both entries newest first, but got %+v", entries)
+	}
+
+	entries, err = s.AuditLogs.List(ctx, store.AuditLogQuery{Limit: 10, ActorID: actor.ID, Action: "user.login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected one login entry, but got %d", len(entries))
+	}
+}
+
+func testWebhooks(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	owner := newUser(t, s)
+	other := newUser(t, s)
+
+	webhook := &store.Webhook{
+		UserID: owner.ID,
+		URL:    "https://example.com/hook",
+		Secret: "secret",
+		Events: []string{"post.created"},
+	}
+	if err := s.Webhooks.Create(ctx, webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	enqueued, err := s.Webhooks.Enqueue(ctx, store.WebhookEvent{
+		ID:      uuid.New().String(),
+		Type:    "post.created",
+		ActorID: owner.ID,
+		Payload: json.RawMessage(`{"id":1}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enqueued != 1 {
+		t.Fatalf("Expected 1 delivery, but got %d", enqueued)
+	}
+
+	claimed, err := s.Webhooks.ClaimDue(ctx, 100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delivery *store.WebhookDelivery
+	for _, d := range claimed {
+		if d.WebhookID == webhook.ID {
+			delivery = d
+		}
+	}
+	if delivery == nil || delivery.Secret != "secret" || delivery.URL != webhook.URL {
+		t.Fatalf("Expected the delivery to be claimed with its webhook, but got %+v", delivery)
+	}
+
+	status := 200
+	delivery.Status = store.DeliverySucceeded
+	delivery.Attempts = 1
+	delivery.ResponseStatus = &status
+	if err := s.Webhooks.RecordAttempt(ctx, delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := s.Webhooks.GetDeliveries(ctx, webhook.ID, owner.ID, store.WebhookDeliveryQuery{Limit: 10, Status: store.DeliverySucceeded})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Attempts != 1 {
+		t.Errorf("Expected the recorded attempt, but got %+v", deliveries)
+	}
+
+	expectErr(t, s.Webhooks.Redeliver(ctx, delivery.ID, webhook.ID, other.ID), store.ErrNotFound)
+	if err := s.Webhooks.Redeliver(ctx, delivery.ID, webhook.ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	expectErr(t, s.Webhooks.Delete(ctx, webhook.ID, other.ID), store.ErrNotFound)
+	if err := s.Webhooks.Delete(ctx, webhook.ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	webhooks, err := s.Webhooks.GetByUserID(ctx, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks) != 0 {
+		t.Errorf("Expected no webhooks after deleting, but got %+v", webhooks)
+	}
+}
+
+func testConcurrency(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	user := newUser(t, s)
+	post := newPost(t, s, user.ID)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		updated int
+		errs    []error
+	)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			update := *post
+			update.Title = fmt.Sprintf("update %d", i)
+			err := s.Posts.UpdatePost(ctx, &update)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				updated++
+			case !errors.Is(err, store.ErrConflict):
+				errs = append(errs, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if updated != 1 {
+		t.Errorf("Expected exactly one update of the same version, but got %d", updated)
+	}
+}
+
+func testTransactions(t *testing.T, s store.Storage) {
+	ctx := context.Background()
+	user := newUser(t, s)
+
+	t.Run("Should commit the changes of every store together", func(t *testing.T) {
+		var post store.Post
+		err := s.Tx.InTx(ctx, nil, func(ctx context.Context) error {
+			post = store.Post{Title: "title", Content: "content", UserID: user.ID}
+			if err := s.Posts.Create(ctx, &post); err != nil {
+				return err
+			}
+			return s.Comments.Create(ctx, &store.Comment{PostID: post.ID, UserID: user.ID, Content: "comment"})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		comments, err := s.Comments.GetByPostID(ctx, post.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(comments) != 1 {
+			t.Errorf("Expected the comment to be committed with its post, but got %d comments", len(comments))
+		}
+	})
+
+	t.Run("Should roll back every store on errors", func(t *testing.T) {
+		errAbort := errors.New("abort")
+
+		var post store.Post
+		err := s.Tx.InTx(ctx, nil, func(ctx context.Context) error {
+			post = store.Post{Title: "title", Content: "content", UserID: user.ID}
+			if err := s.Posts.Create(ctx, &post); err != nil {
+				return err
+			}
+			if err := s.Followers.Follow(ctx, user.ID, newUser(t, s).ID); err != nil {
+				return err
+			}
+
+			// a nested unit of work joins the outer one.
+			return s.Tx.InTx(ctx, nil, func(ctx context.Context) error {
+				return errAbort
+			})
+		})
+		expectErr(t, err, errAbort)
+
+		_, err = s.Posts.GetPostByID(ctx, int(post.ID))
+		expectErr(t, err, store.ErrNotFound)
+
+		following, err := s.Followers.GetFollowing(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(following) != 0 {
+			t.Errorf("Expected the follow to be rolled back, but got %+v", following)
+		}
+	})
+
+	t.Run("Should keep the writes made outside a rolled back transaction", func(t *testing.T) {
+		errAbort := errors.New("abort")
+
+		var inside, outside *store.Post
+		var other *store.User
+		err := s.Tx.InTx(ctx, nil, func(txCtx context.Context) error {
+			inside = &store.Post{Title: "title", Content: "content", UserID: user.ID}
+			if err := s.Posts.Create(txCtx, inside); err != nil {
+				return err
+			}
+
+			// another request writes while the transaction runs.
+			other = newUser(t, s)
+			outside = newPost(t, s, other.ID)
+			if err := s.Followers.Follow(ctx, other.ID, user.ID); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		expectErr(t, err, errAbort)
+
+		_, err = s.Posts.GetPostByID(ctx, int(inside.ID))
+		expectErr(t, err, store.ErrNotFound)
+
+		if _, err := s.Posts.GetPostByID(ctx, int(outside.ID)); err != nil {
+			t.Errorf("Expected the post created outside the transaction, but got %v", err)
+		}
+		if _, err := s.Users.GetUserByID(ctx, other.ID); err != nil {
+			t.Errorf("Expected the user created outside the transaction, but got %v", err)
+		}
+
+		following, err := s.Followers.GetFollowing(ctx, other.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(following) != 1 {
+			t.Errorf("Expected the follow made outside the transaction, but got %+v", following)
+		}
+	})
+}
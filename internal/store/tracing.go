@@ -0,0 +1,329 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/MohummedSoliman/social/internal/store")
+
+// WithTracing wraps every store of s so each call is recorded as a span
+// named after the store method.
+func WithTracing(s Storage) Storage {
+	return Storage{
+		Posts:     &tracedPosts{s.Posts},
+		Users:     &tracedUsers{s.Users},
+		Comments:  &tracedComments{s.Comments},
+		Followers: &tracedFollowers{s.Followers},
+		Roles:     &tracedRoles{s.Roles},
+		AuditLogs: &tracedAuditLogs{s.AuditLogs},
+		Webhooks:  &tracedWebhooks{s.Webhooks},
+		Tx:        &tracedTx{s.Tx},
+	}
+}
+
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "store."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func traceExec(ctx context.Context, name string, fn func(context.Context) error) error {
+	ctx, span := startSpan(ctx, name)
+	err := fn(ctx)
+	endSpan(span, err)
+	return err
+}
+
+func traceQuery[T any](ctx context.Context, name string, fn func(context.Context) (T, error)) (T, error) {
+	ctx, span := startSpan(ctx, name)
+	res, err := fn(ctx)
+	if v := reflect.ValueOf(res); v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		span.SetAttributes(attribute.Int("db.response.returned_rows", v.Len()))
+	}
+	endSpan(span, err)
+	return res, err
+}
+
+// setRowsAffected annotates the current store span with the rows a
+// statement changed.
+func setRowsAffected(ctx context.Context, rows int64) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("db.rows_affected", rows))
+}
+
+type tracedPosts struct{ Posts }
+
+func (t *tracedPosts) Create(ctx context.Context, post *Post) error {
+	return traceExec(ctx, "posts.Create", func(ctx context.Context) error {
+		return t.Posts.Create(ctx, post)
+	})
+}
+
+func (t *tracedPosts) GetPostByID(ctx context.Context, postID int) (*Post, error) {
+	return traceQuery(ctx, "posts.GetPostByID", func(ctx context.Context) (*Post, error) {
+		return t.Posts.GetPostByID(ctx, postID)
+	})
+}
+
+func (t *tracedPosts) DeletePostByID(ctx context.Context, postID int64, version int) error {
+	return traceExec(ctx, "posts.DeletePostByID", func(ctx context.Context) error {
+		return t.Posts.DeletePostByID(ctx, postID, version)
+	})
+}
+
+func (t *tracedPosts) UpdatePost(ctx context.Context, post *Post) error {
+	return traceExec(ctx, "posts.UpdatePost", func(ctx context.Context) error {
+		return t.Posts.UpdatePost(ctx, post)
+	})
+}
+
+func (t *tracedPosts) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return traceQuery(ctx, "posts.GetUserFeed", func(ctx context.Context) ([]PostWithMetadata, error) {
+		return t.Posts.GetUserFeed(ctx, userID, fq)
+	})
+}
+
+func (t *tracedPosts) GetByUserID(ctx context.Context, userID int64) ([]*Post, error) {
+	return traceQuery(ctx, "posts.GetByUserID", func(ctx context.Context) ([]*Post, error) {
+		return t.Posts.GetByUserID(ctx, userID)
+	})
+}
+
+type tracedUsers struct{ Users }
+
+func (t *tracedUsers) Create(ctx context.Context, user *User) error {
+	return traceExec(ctx, "users.Create", func(ctx context.Context) error {
+		return t.Users.Create(ctx, user)
+	})
+}
+
+func (t *tracedUsers) GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	return traceQuery(ctx, "users.GetUserByID", func(ctx context.Context) (*User, error) {
+		return t.Users.GetUserByID(ctx, userID)
+	})
+}
+
+func (t *tracedUsers) CreateAndInviate(ctx context.Context, user *User, token string, exp time.Duration) error {
+	return traceExec(ctx, "users.CreateAndInviate", func(ctx context.Context) error {
+		return t.Users.CreateAndInviate(ctx, user, token, exp)
+	})
+}
+
+func (t *tracedUsers) ActivateUser(ctx context.Context, token string) (*User, error) {
+	return traceQuery(ctx, "users.ActivateUser", func(ctx context.Context) (*User, error) {
+		return t.Users.ActivateUser(ctx, token)
+	})
+}
+
+func (t *tracedUsers) Delete(ctx context.Context, userID int64) error {
+	return traceExec(ctx, "users.Delete", func(ctx context.Context) error {
+		return t.Users.Delete(ctx, userID)
+	})
+}
+
+func (t *tracedUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return traceQuery(ctx, "users.GetByEmail", func(ctx context.Context) (*User, error) {
+		return t.Users.GetByEmail(ctx, email)
+	})
+}
+
+func (t *tracedUsers) RequestEmailChange(ctx context.Context, userID int64, email, token string, exp time.Duration) error {
+	return traceExec(ctx, "users.RequestEmailChange", func(ctx context.Context) error {
+		return t.Users.RequestEmailChange(ctx, userID, email, token, exp)
+	})
+}
+
+func (t *tracedUsers) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	return traceQuery(ctx, "users.ConfirmEmailChange", func(ctx context.Context) (*User, error) {
+		return t.Users.ConfirmEmailChange(ctx, token)
+	})
+}
+
+func (t *tracedUsers) SoftDelete(ctx context.Context, userID int64) error {
+	return traceExec(ctx, "users.SoftDelete", func(ctx context.Context) error {
+		return t.Users.SoftDelete(ctx, userID)
+	})
+}
+
+func (t *tracedUsers) PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (Purged, error) {
+	return traceQuery(ctx, "users.PurgeDeleted", func(ctx context.Context) (Purged, error) {
+		return t.Users.PurgeDeleted(ctx, gracePeriod)
+	})
+}
+
+func (t *tracedUsers) UpdateRole(ctx context.Context, userID, roleID int64) error {
+	return traceExec(ctx, "users.UpdateRole", func(ctx context.Context) error {
+		return t.Users.UpdateRole(ctx, userID, roleID)
+	})
+}
+
+func (t *tracedUsers) Suspend(ctx context.Context, userID int64, reason string, until *time.Time) error {
+	return traceExec(ctx, "users.Suspend", func(ctx context.Context) error {
+		return t.Users.Suspend(ctx, userID, reason, until)
+	})
+}
+
+func (t *tracedUsers) Unsuspend(ctx context.Context, userID int64) error {
+	return traceExec(ctx, "users.Unsuspend", func(ctx context.Context) error {
+		return t.Users.Unsuspend(ctx, userID)
+	})
+}
+
+type tracedComments struct{ Comments }
+
+func (t *tracedComments) GetByPostID(ctx context.Context, postID int64) ([]*Comment, error) {
+	return traceQuery(ctx, "comments.GetByPostID", func(ctx context.Context) ([]*Comment, error) {
+		return t.Comments.GetByPostID(ctx, postID)
+	})
+}
+
+func (t *tracedComments) Create(ctx context.Context, comment *Comment) error {
+	return traceExec(ctx, "comments.Create", func(ctx context.Context) error {
+		return t.Comments.Create(ctx, comment)
+	})
+}
+
+func (t *tracedComments) GetByUserID(ctx context.Context, userID int64) ([]*Comment, error) {
+	return traceQuery(ctx, "comments.GetByUserID", func(ctx context.Context) ([]*Comment, error) {
+		return t.Comments.GetByUserID(ctx, userID)
+	})
+}
+
+type tracedFollowers struct{ Followers }
+
+func (t *tracedFollowers) Follow(ctx context.Context, followerID, userID int64) error {
+	return traceExec(ctx, "followers.Follow", func(ctx context.Context) error {
+		return t.Followers.Follow(ctx, followerID, userID)
+	})
+}
+
+func (t *tracedFollowers) UnFollow(ctx context.Context, unfollowedID, userID int64) error {
+	return traceExec(ctx, "followers.UnFollow", func(ctx context.Context) error {
+		return t.Followers.UnFollow(ctx, unfollowedID, userID)
+	})
+}
+
+func (t *tracedFollowers) GetFollowers(ctx context.Context, userID int64) ([]Follower, error) {
+	return traceQuery(ctx, "followers.GetFollowers", func(ctx context.Context) ([]Follower, error) {
+		return t.Followers.GetFollowers(ctx, userID)
+	})
+}
+
+func (t *tracedFollowers) GetFollowing(ctx context.Context, userID int64) ([]Follower, error) {
+	return traceQuery(ctx, "followers.GetFollowing", func(ctx context.Context) ([]Follower, error) {
+		return t.Followers.GetFollowing(ctx, userID)
+	})
+}
+
+type tracedRoles struct{ Roles }
+
+func (t *tracedRoles) GetByName(ctx context.Context, name string) (*Role, error) {
+	return traceQuery(ctx, "roles.GetByName", func(ctx context.Context) (*Role, error) {
+		return t.Roles.GetByName(ctx, name)
+	})
+}
+
+func (t *tracedRoles) GetAll(ctx context.Context) ([]*Role, error) {
+	return traceQuery(ctx, "roles.GetAll", func(ctx context.Context) ([]*Role, error) {
+		return t.Roles.GetAll(ctx)
+	})
+}
+
+func (t *tracedRoles) GetPermissions(ctx context.Context) (map[int64][]string, error) {
+	return traceQuery(ctx, "roles.GetPermissions", func(ctx context.Context) (map[int64][]string, error) {
+		return t.Roles.GetPermissions(ctx)
+	})
+}
+
+type tracedAuditLogs struct{ AuditLogs }
+
+func (t *tracedAuditLogs) Create(ctx context.Context, entry *AuditEntry) error {
+	return traceExec(ctx, "audit_log.Create", func(ctx context.Context) error {
+		return t.AuditLogs.Create(ctx, entry)
+	})
+}
+
+func (t *tracedAuditLogs) List(ctx context.Context, q AuditLogQuery) ([]*AuditEntry, error) {
+	return traceQuery(ctx, "audit_log.List", func(ctx context.Context) ([]*AuditEntry, error) {
+		return t.AuditLogs.List(ctx, q)
+	})
+}
+
+type tracedWebhooks struct{ Webhooks }
+
+func (t *tracedWebhooks) Create(ctx context.Context, webhook *Webhook) error {
+	return traceExec(ctx, "webhooks.Create", func(ctx context.Context) error {
+		return t.Webhooks.Create(ctx, webhook)
+	})
+}
+
+func (t *tracedWebhooks) GetByUserID(ctx context.Context, userID int64) ([]*Webhook, error) {
+	return traceQuery(ctx, "webhooks.GetByUserID", func(ctx context.Context) ([]*Webhook, error) {
+		return t.Webhooks.GetByUserID(ctx, userID)
+	})
+}
+
+func (t *tracedWebhooks) Delete(ctx context.Context, webhookID, userID int64) error {
+	return traceExec(ctx, "webhooks.Delete", func(ctx context.Context) error {
+		return t.Webhooks.Delete(ctx, webhookID, userID)
+	})
+}
+
+func (t *tracedWebhooks) Enqueue(ctx context.Context, event WebhookEvent) (int64, error) {
+	return traceQuery(ctx, "webhooks.Enqueue", func(ctx context.Context) (int64, error) {
+		return t.Webhooks.Enqueue(ctx, event)
+	})
+}
+
+func (t *tracedWebhooks) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	return traceQuery(ctx, "webhooks.ClaimDue", func(ctx context.Context) ([]*WebhookDelivery, error) {
+		return t.Webhooks.ClaimDue(ctx, limit, lease)
+	})
+}
+
+func (t *tracedWebhooks) RecordAttempt(ctx context.Context, delivery *WebhookDelivery) error {
+	return traceExec(ctx, "webhooks.RecordAttempt", func(ctx context.Context) error {
+		return t.Webhooks.RecordAttempt(ctx, delivery)
+	})
+}
+
+func (t *tracedWebhooks) GetDeliveries(ctx context.Context, webhookID, userID int64, q WebhookDeliveryQuery) ([]*WebhookDelivery, error) {
+	return traceQuery(ctx, "webhooks.GetDeliveries", func(ctx context.Context) ([]*WebhookDelivery, error) {
+		return t.Webhooks.GetDeliveries(ctx, webhookID, userID, q)
+	})
+}
+
+func (t *tracedWebhooks) Redeliver(ctx context.Context, deliveryID, webhookID, userID int64) error {
+	return traceExec(ctx, "webhooks.Redeliver", func(ctx context.Context) error {
+		return t.Webhooks.Redeliver(ctx, deliveryID, webhookID, userID)
+	})
+}
+
+type tracedTx struct{ Transactor }
+
+// InTx records the transaction as a span, the store calls of fn nest in it.
+func (t *tracedTx) InTx(ctx context.Context, opts *sql.TxOptions, fn func(context.Context) error) error {
+	return traceExec(ctx, "tx", func(ctx context.Context) error {
+		return t.Transactor.InTx(ctx, opts, fn)
+	})
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/logger"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TxRetries is how many times a transaction is run again after a
+// serialization failure or a deadlock.
+var TxRetries = 3
+
+// querier is what the stores run queries on, the pool or a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txContextKey struct{}
+
+// txConn is the transaction of a unit of work with the connection it runs
+// on, which pgx calls go through.
+type txConn struct {
+	*sql.Tx
+	conn *sql.Conn
+}
+
+func txFromContext(ctx context.Context) (*txConn, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*txConn)
+	return tx, ok
+}
+
+// conn returns the transaction of ctx, if any, so store methods called
+// inside Transactor.InTx join it.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx.Tx
+	}
+	return db
+}
+
+type afterCommitContextKey struct{}
+
+// afterCommit holds the funcs to run once a transaction committed.
+type afterCommit struct {
+	mu  sync.Mutex
+	fns []func(context.Context)
+}
+
+// AfterCommit runs fn once the transaction of ctx committed, or right away
+// when ctx has none. fn is dropped when the transaction rolls back, and is
+// run at most once however often the transaction is retried. Caches are
+// invalidated this way, so no reader fills them with the data the
+// transaction is about to replace.
+func AfterCommit(ctx context.Context, fn func(context.Context)) {
+	hooks, ok := ctx.Value(afterCommitContextKey{}).(*afterCommit)
+	if !ok {
+		fn(ctx)
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.fns = append(hooks.fns, fn)
+}
+
+func withAfterCommit(ctx context.Context) (context.Context, *afterCommit) {
+	hooks := &afterCommit{}
+	return context.WithValue(ctx, afterCommitContextKey{}, hooks), hooks
+}
+
+func (h *afterCommit) run(ctx context.Context) {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
+
+// TxStore runs units of work spanning several stores in one transaction.
+type TxStore struct {
+	db *sql.DB
+}
+
+// InTx runs fn in a transaction with opts, nil for the defaults. Every
+// store method called with the context fn gets joins the transaction, a
+// nested InTx too. fn is run again on serialization failures, so it must
+// not have side effects outside the database.
+func (s *TxStore) InTx(ctx context.Context, opts *sql.TxOptions, fn func(context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := s.run(ctx, opts, fn)
+		if !retryable(err) || attempt >= TxRetries {
+			return err
+		}
+
+		logger.FromContext(ctx).Warn("retrying transaction", "attempt", attempt+1, "error", err.Error())
+
+		backoff := time.Duration(rand.Int64N(int64(time.Millisecond * 10 << attempt)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (s *TxStore) run(ctx context.Context, opts *sql.TxOptions, fn func(context.Context) error) error {
+	c, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	tx, err := c.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	txCtx, hooks := withAfterCommit(context.WithValue(ctx, txContextKey{}, &txConn{Tx: tx, conn: c}))
+	if err := fn(txCtx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			logger.FromContext(ctx).Error("error rolling back transaction", "error", rbErr.Error())
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	hooks.run(ctx)
+	return nil
+}
+
+// retryable reports a serialization failure or a deadlock, the transaction
+// can succeed when run again.
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
@@ -25,6 +25,40 @@ type User struct {
 	IsActive  bool     `json:"is_active"`
 	RoleID    int64    `json:"role_id"`
 	Role      Role     `json:"role"`
+
+	Suspension *Suspension `json:"suspension,omitempty"`
+}
+
+type Suspension struct {
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+	Until     *time.Time `json:"until,omitempty"` // nil means the user is banned.
+}
+
+func (s *Suspension) Active() bool {
+	if s == nil {
+		return false
+	}
+	return s.Until == nil || s.Until.After(time.Now())
+}
+
+func (u *User) IsSuspended() bool {
+	return u.Suspension.Active()
+}
+
+func newSuspension(at, until sql.NullTime, reason sql.NullString) *Suspension {
+	if !at.Valid {
+		return nil
+	}
+
+	s := &Suspension{
+		Reason:    reason.String,
+		CreatedAt: at.Time,
+	}
+	if until.Valid {
+		s.Until = &until.Time
+	}
+	return s
 }
 
 type password struct {
@@ -52,23 +86,27 @@ type UserStore struct {
 	db *sql.DB
 }
 
-func (u *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
+func (u *UserStore) Create(ctx context.Context, user *User) error {
+	return u.create(ctx, conn(ctx, u.db), user)
+}
+
+func (u *UserStore) create(ctx context.Context, q querier, user *User) error {
 	query := `INSERT INTO users (username, email, password, role_id) VALUES ($1, $2, $3, $4)
 			  RETURNING id, created_at`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	row := tx.QueryRowContext(ctx, query, user.Username, user.Email, user.Password.hash, user.RoleID)
+	row := q.QueryRowContext(ctx, query, user.Username, user.Email, user.Password.hash, user.RoleID)
 	err := row.Scan(
 		&user.ID,
 		&user.CreatedAt,
 	)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case isUniqueViolation(err, "users_email_key"):
 			return ErrDuplicateEmail
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+		case isUniqueViolation(err, "users_username_key"):
 			return ErrDuplicateUsername
 		default:
 			return err
@@ -78,21 +116,31 @@ func (u *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 }
 
 func (u *UserStore) GetUserByID(ctx context.Context, userID int64) (*User, error) {
-	query := `SELECT u.id, u.username, u.email, u.password, u.created_at, r.id, r.name, r.level, r.description
+	query := `SELECT u.id, u.username, u.email, u.password, u.created_at, u.is_active, u.role_id,
+			  u.suspended_at, u.suspended_until, u.suspension_reason, r.id, r.name, r.level, r.description
 			  FROM users u JOIN roles r ON u.role_id = r.id
-			  WHERE u.id = $1`
+			  WHERE u.id = $1 AND u.deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var user User
-	row := u.db.QueryRowContext(ctx, query, userID)
+	var (
+		user                       User
+		suspendedAt, suspendedTill sql.NullTime
+		suspensionReason           sql.NullString
+	)
+	row := conn(ctx, u.db).QueryRowContext(ctx, query, userID)
 	err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.IsActive,
+		&user.RoleID,
+		&suspendedAt,
+		&suspendedTill,
+		&suspensionReason,
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -106,13 +154,14 @@ func (u *UserStore) GetUserByID(ctx context.Context, userID int64) (*User, error
 			return nil, err
 		}
 	}
+	user.Suspension = newSuspension(suspendedAt, suspendedTill, suspensionReason)
 
 	return &user, nil
 }
 
 func (u *UserStore) CreateAndInviate(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
 	return WithTransaction(u.db, ctx, func(tx *sql.Tx) error {
-		if err := u.Create(ctx, tx, user); err != nil {
+		if err := u.create(ctx, tx, user); err != nil {
 			return err
 		}
 
@@ -137,9 +186,12 @@ func (u *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, userID
 	return nil
 }
 
-func (u *UserStore) ActivateUser(ctx context.Context, token string) error {
-	return WithTransaction(u.db, ctx, func(tx *sql.Tx) error {
-		user, err := u.getUserFromInvitation(ctx, tx, token)
+// ActivateUser activates the user invited with token and returns it.
+func (u *UserStore) ActivateUser(ctx context.Context, token string) (*User, error) {
+	var user *User
+	err := WithTransaction(u.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = u.getUserFromInvitation(ctx, tx, token)
 		if err != nil {
 			return err
 		}
@@ -156,6 +208,11 @@ func (u *UserStore) ActivateUser(ctx context.Context, token string) error {
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
 func (u *UserStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userID int64) error {
@@ -173,7 +230,7 @@ func (u *UserStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userID
 }
 
 func (u *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
-	stmt := `UPDATE users SET is_active = TRUE WHERE id = $1 AND is_active = true`
+	stmt := `UPDATE users SET is_active = TRUE WHERE id = $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -248,23 +305,250 @@ func (u *UserStore) deleteUser(ctx context.Context, tx *sql.Tx, userID int64) er
 	if err != nil {
 		return err
 	}
+	setRowsAffected(ctx, row)
 
 	if row == 0 {
-		return sql.ErrNoRows
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-func (u *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, username, email, password, created_at, is_active FROM users
-			  WHERE email = $1 AND is_active = true`
+func (u *UserStore) RequestEmailChange(ctx context.Context, userID int64, email, token string, exp time.Duration) error {
+	return WithTransaction(u.db, ctx, func(tx *sql.Tx) error {
+		// a new request replaces any change that is still pending.
+		if err := u.deleteEmailChanges(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO user_email_changes (token, user_id, new_email, expiry) VALUES ($1, $2, $3, $4)`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, stmt, token, userID, email, time.Now().Add(exp))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (u *UserStore) ConfirmEmailChange(ctx context.Context, token string) (*User, error) {
+	var user *User
+	err := WithTransaction(u.db, ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = u.getUserFromEmailChange(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if err := u.updateEmail(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return u.deleteEmailChanges(ctx, tx, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (u *UserStore) getUserFromEmailChange(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+	query := `SELECT u.id, u.username, uec.new_email, u.created_at, u.is_active
+			  FROM users u JOIN user_email_changes uec
+			  ON u.id = uec.user_id WHERE uec.token = $1 AND uec.expiry > $2`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
+	hash := sha256.Sum256([]byte(token))
+	hashedToken := hex.EncodeToString(hash[:])
+
 	var user User
-	row := u.db.QueryRowContext(ctx, query, email)
+	row := tx.QueryRowContext(ctx, query, hashedToken, time.Now())
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (u *UserStore) updateEmail(ctx context.Context, tx *sql.Tx, user *User) error {
+	stmt := `UPDATE users SET email = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, stmt, user.Email, user.ID)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "users_email_key"):
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *UserStore) deleteEmailChanges(ctx context.Context, tx *sql.Tx, userID int64) error {
+	stmt := `DELETE FROM user_email_changes WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, stmt, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *UserStore) UpdateRole(ctx context.Context, userID, roleID int64) error {
+	stmt := `UPDATE users SET role_id = $1 WHERE id = $2 AND deleted_at IS NULL`
+	return u.execForUser(ctx, stmt, roleID, userID)
+}
+
+// Suspend blocks the user until the given time, a nil until bans the user permanently.
+func (u *UserStore) Suspend(ctx context.Context, userID int64, reason string, until *time.Time) error {
+	stmt := `UPDATE users SET suspended_at = NOW(), suspended_until = $1, suspension_reason = $2
+			 WHERE id = $3 AND deleted_at IS NULL`
+	return u.execForUser(ctx, stmt, until, reason, userID)
+}
+
+func (u *UserStore) Unsuspend(ctx context.Context, userID int64) error {
+	stmt := `UPDATE users SET suspended_at = NULL, suspended_until = NULL, suspension_reason = NULL
+			 WHERE id = $1 AND deleted_at IS NULL`
+	return u.execForUser(ctx, stmt, userID)
+}
+
+// execForUser runs stmt and reports ErrNotFound when no user was affected.
+func (u *UserStore) execForUser(ctx context.Context, stmt string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := conn(ctx, u.db).ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	setRowsAffected(ctx, rows)
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (u *UserStore) SoftDelete(ctx context.Context, userID int64) error {
+	stmt := `UPDATE users SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	return u.execForUser(ctx, stmt, userID)
+}
+
+// Purged is what PurgeDeleted removed.
+type Purged struct {
+	Users int64
+	// PostIDs are the posts that were deleted or lost comments.
+	PostIDs []int64
+}
+
+// PurgeDeleted permanently removes the users soft deleted more than
+// gracePeriod ago together with everything they own.
+func (u *UserStore) PurgeDeleted(ctx context.Context, gracePeriod time.Duration) (Purged, error) {
+	expired := `SELECT id FROM users WHERE deleted_at < $1`
+	affected := `SELECT id FROM posts WHERE user_id IN (` + expired + `)
+				 UNION SELECT post_id FROM comments WHERE user_id IN (` + expired + `)`
+	stmts := []string{
+		`DELETE FROM comments WHERE user_id IN (` + expired + `)
+		 OR post_id IN (SELECT id FROM posts WHERE user_id IN (` + expired + `))`,
+		`DELETE FROM posts WHERE user_id IN (` + expired + `)`,
+		`DELETE FROM followers WHERE user_id IN (` + expired + `) OR follower_id IN (` + expired + `)`,
+		`DELETE FROM user_invitations WHERE user_id IN (` + expired + `)`,
+		`DELETE FROM user_email_changes WHERE user_id IN (` + expired + `)`,
+	}
+
+	before := time.Now().Add(-gracePeriod)
+
+	var purged Purged
+	err := WithTransaction(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, affected, before)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var postID int64
+			if err := rows.Scan(&postID); err != nil {
+				return err
+			}
+			purged.PostIDs = append(purged.PostIDs, postID)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt, before); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE deleted_at < $1`, before)
+		if err != nil {
+			return err
+		}
+
+		purged.Users, err = res.RowsAffected()
+		setRowsAffected(ctx, purged.Users)
+		return err
+	})
+	if err != nil {
+		return Purged{}, err
+	}
+
+	return purged, nil
+}
+
+func (u *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
+	query := `SELECT id, username, email, password, created_at, is_active,
+			  suspended_at, suspended_until, suspension_reason FROM users
+			  WHERE email = $1 AND is_active = true AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var (
+		user                       User
+		suspendedAt, suspendedTill sql.NullTime
+		suspensionReason           sql.NullString
+	)
+	row := conn(ctx, u.db).QueryRowContext(ctx, query, email)
 	err := row.Scan(
 		&user.ID,
 		&user.Username,
@@ -272,6 +556,9 @@ func (u *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Password.hash,
 		&user.CreatedAt,
 		&user.IsActive,
+		&suspendedAt,
+		&suspendedTill,
+		&suspensionReason,
 	)
 	if err != nil {
 		switch err {
@@ -281,6 +568,7 @@ func (u *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 			return nil, err
 		}
 	}
+	user.Suspension = newSuspension(suspendedAt, suspendedTill, suspensionReason)
 
 	return &user, nil
 }
@@ -0,0 +1,53 @@
+package store_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MohummedSoliman/social/internal/store"
+	"github.com/google/uuid"
+)
+
+// TestUserStore needs a migrated database, set TEST_DB_ADDR to run it.
+func TestUserStore(t *testing.T) {
+	_, db := openPostgres(t)
+
+	s := store.NewStorage(db)
+	ctx := context.Background()
+
+	t.Run("Should activate invited users", func(t *testing.T) {
+		name := "test-" + uuid.New().String()
+		user := &store.User{Username: name, Email: name + "@example.com", RoleID: 1}
+		if err := user.Password.Set("password"); err != nil {
+			t.Fatal(err)
+		}
+
+		token := uuid.New().String()
+		hash := sha256.Sum256([]byte(token))
+		if err := s.Users.CreateAndInviate(ctx, user, hex.EncodeToString(hash[:]), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Users.ActivateUser(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Users.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.IsActive {
+			t.Error("Expected the user to be active after activation")
+		}
+	})
+
+	t.Run("Should return ErrNotFound when deleting a missing user", func(t *testing.T) {
+		if err := s.Users.Delete(ctx, -1); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("Expected error %v, but got %v", store.ErrNotFound, err)
+		}
+	})
+}
@@ -0,0 +1,347 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID        int64    `json:"id"`
+	UserID    int64    `json:"user_id"`
+	URL       string   `json:"url"`
+	Secret    string   `json:"-"`
+	Events    []string `json:"events"`
+	Global    bool     `json:"global"`
+	CreatedAt string   `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      string          `json:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	CreatedAt      string          `json:"created_at"`
+	UpdatedAt      string          `json:"updated_at"`
+
+	// URL and Secret of the webhook, only loaded for sending.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookDeliveryQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=100"`
+	Offset int    `json:"offset" validate:"gte=0"`
+	Status string `json:"status" validate:"omitempty,oneof=pending succeeded failed"`
+}
+
+func (q WebhookDeliveryQuery) Parse(r *http.Request) (WebhookDeliveryQuery, error) {
+	qs := r.URL.Query()
+
+	limit := qs.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = l
+	}
+
+	offset := qs.Get("offset")
+	if offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return q, err
+		}
+		q.Offset = o
+	}
+
+	if status := qs.Get("status"); status != "" {
+		q.Status = status
+	}
+
+	return q, nil
+}
+
+// WebhookEvent is an event to deliver to the webhooks subscribed to its
+// type whose owner is allowed to see it.
+type WebhookEvent struct {
+	ID          string
+	Type        string
+	ActorID     int64
+	Recipients  []int64
+	FollowersOf int64
+	Payload     json.RawMessage
+}
+
+type WebhookStore struct {
+	db *sql.DB
+}
+
+func (s *WebhookStore) Create(ctx context.Context, webhook *Webhook) error {
+	query := `INSERT INTO webhooks (user_id, url, secret, events, global)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return conn(ctx, s.db).QueryRowContext(
+		ctx,
+		query,
+		webhook.UserID,
+		webhook.URL,
+		webhook.Secret,
+		webhook.Events,
+		webhook.Global,
+	).Scan(&webhook.ID, &webhook.CreatedAt)
+}
+
+func (s *WebhookStore) GetByUserID(ctx context.Context, userID int64) ([]*Webhook, error) {
+	query := `SELECT id, user_id, url, events, global, created_at FROM webhooks
+			  WHERE user_id = $1 ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		var w Webhook
+		err := rows.Scan(
+			&w.ID,
+			&w.UserID,
+			&w.URL,
+			array(&w.Events),
+			&w.Global,
+			&w.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, webhookID, userID int64) error {
+	query := `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, webhookID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	setRowsAffected(ctx, rows)
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Enqueue creates a pending delivery of event for every matching webhook
+// and returns how many were created.
+func (s *WebhookStore) Enqueue(ctx context.Context, event WebhookEvent) (int64, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+			  SELECT w.id, $1::varchar, $2::varchar, $3::jsonb FROM webhooks w
+			  JOIN users u ON u.id = w.user_id
+			  WHERE $2 = ANY(w.events) AND u.deleted_at IS NULL AND (
+			      w.global OR w.user_id = $4 OR w.user_id = ANY($5) OR
+			      EXISTS (SELECT 1 FROM followers f WHERE f.follower_id = w.user_id AND f.user_id = $6)
+			  )`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := conn(ctx, s.db).ExecContext(
+		ctx,
+		query,
+		event.ID,
+		event.Type,
+		nullJSON(event.Payload),
+		event.ActorID,
+		event.Recipients,
+		event.FollowersOf,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	setRowsAffected(ctx, rows)
+
+	return rows, nil
+}
+
+// ClaimDue returns up to limit pending deliveries that are due and pushes
+// their next attempt back by lease, so other instances skip them while they
+// are being sent.
+func (s *WebhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries d SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+			  FROM webhooks w
+			  WHERE w.id = d.webhook_id AND d.id IN (
+			      SELECT id FROM webhook_deliveries
+			      WHERE status = 'pending' AND next_attempt_at <= NOW()
+			      ORDER BY next_attempt_at LIMIT $1
+			      FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+			  d.response_status, d.last_error, d.next_attempt_at, d.created_at, d.updated_at, w.url, w.secret`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.EventType,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.NextAttemptAt,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+			&d.URL,
+			&d.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordAttempt stores the outcome of the last attempt of delivery.
+func (s *WebhookStore) RecordAttempt(ctx context.Context, delivery *WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries SET status = $1, attempts = $2, response_status = $3,
+			  last_error = $4, next_attempt_at = $5, updated_at = NOW()
+			  WHERE id = $6`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := conn(ctx, s.db).ExecContext(
+		ctx,
+		query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		delivery.ID,
+	)
+	return err
+}
+
+func (s *WebhookStore) GetDeliveries(ctx context.Context, webhookID, userID int64, q WebhookDeliveryQuery) ([]*WebhookDelivery, error) {
+	query := `SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+			  d.response_status, d.last_error, d.next_attempt_at, d.created_at, d.updated_at
+			  FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			  WHERE d.webhook_id = $1 AND w.user_id = $2 AND ($3 = '' OR d.status = $3)
+			  ORDER BY d.id DESC LIMIT $4 OFFSET $5`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := conn(ctx, s.db).QueryContext(ctx, query, webhookID, userID, q.Status, q.Limit, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(
+			&d.ID,
+			&d.WebhookID,
+			&d.EventID,
+			&d.EventType,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.NextAttemptAt,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// Redeliver queues a delivery of one of the webhooks of userID again, with
+// a fresh set of attempts.
+func (s *WebhookStore) Redeliver(ctx context.Context, deliveryID, webhookID, userID int64) error {
+	query := `UPDATE webhook_deliveries d SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+			  FROM webhooks w
+			  WHERE w.id = d.webhook_id AND d.id = $1 AND d.webhook_id = $2 AND w.user_id = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := conn(ctx, s.db).ExecContext(ctx, query, deliveryID, webhookID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Package webhook signs and sends event deliveries to subscriber URLs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	EventHeader     = "X-Social-Event"
+	DeliveryHeader  = "X-Social-Delivery"
+	TimestampHeader = "X-Social-Timestamp"
+	SignatureHeader = "X-Social-Signature"
+
+	signaturePrefix = "sha256="
+
+	// maxResponseBody is how much of a receiver response is read so the
+	// connection can be reused.
+	maxResponseBody = 1024
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidURL       = errors.New("webhook url must be an http or https URL")
+	ErrAddressForbidden = errors.New("webhook address is not allowed")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range, it is not public but
+// not covered by netip.Addr.IsPrivate either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicAddr reports whether addr may receive deliveries. Loopback, private,
+// link-local (which includes the cloud metadata endpoints), unspecified and
+// multicast addresses are refused so subscribers can't reach internal
+// services through the API.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// CheckURL rejects URLs that are not http(s) or whose host resolves to an
+// address refused by PublicAddr. Deliveries are checked again when dialing
+// since the host may resolve differently by then.
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !PublicAddr(addr) {
+			return ErrAddressForbidden
+		}
+	}
+	return nil
+}
+
+// Request is a single delivery attempt.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID int64
+	Event      string
+	Payload    []byte
+}
+
+// Response is what the receiver answered. Its body is not kept, receivers
+// are not trusted to put anything in the delivery log.
+type Response struct {
+	Status int
+}
+
+func (r Response) OK() bool {
+	return r.Status >= 200 && r.Status < 300
+}
+
+type Client struct {
+	http *http.Client
+	now  func() time.Time
+}
+
+func NewClient(timeout time.Duration) *Client {
+	return newClient(timeout, PublicAddr)
+}
+
+// newClient returns a client that only connects to addresses allowed by
+// allow. The check runs on the resolved address of every connection,
+// redirects included, so a host can't be rebound to a refused address
+// after CheckURL.
+func newClient(timeout time.Duration, allow func(netip.Addr) bool) *Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !allow(addrPort.Addr()) {
+				return ErrAddressForbidden
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed instead of the receiver.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Client{
+		http: &http.Client{Timeout: timeout, Transport: transport},
+		now:  time.Now,
+	}
+}
+
+// Send posts the payload to the receiver, signed with its secret. An error
+// is only returned when no response was received.
+func (c *Client) Send(ctx context.Context, req Request) (Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Payload))
+	if err != nil {
+		return Response{}, err
+	}
+
+	timestamp := c.now()
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "GopherSocial-Webhooks")
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, strconv.FormatInt(req.DeliveryID, 10))
+	httpReq.Header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, timestamp, req.Payload))
+
+	res, err := c.http.Do(httpReq)
+	if err != nil {
+		return Response{}, err
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBody))
+	return Response{Status: res.StatusCode}, nil
+}
+
+// Sign returns the signature header value of payload sent at timestamp.
+// The timestamp is part of the signed content so receivers can reject
+// replayed deliveries.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received request
+// against its body, the timestamp may be at most tolerance old.
+func Verify(secret string, header http.Header, payload []byte, tolerance time.Duration) error {
+	unix, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	timestamp := time.Unix(unix, 0)
+	if time.Since(timestamp) > tolerance {
+		return ErrInvalidSignature
+	}
+
+	signature := header.Get(SignatureHeader)
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Backoff returns how long to wait before the next attempt after the given
+// number of failed attempts: 30s, 1m, 2m, ... capped at 6h.
+func Backoff(attempts int) time.Duration {
+	const (
+		base = time.Second * 30
+		max  = time.Hour * 6
+	)
+
+	d := time.Duration(float64(base) * math.Pow(2, float64(attempts-1)))
+	if d <= 0 || d > max {
+		return max
+	}
+	return d
+}
+
+// NewSecret returns a random secret for a subscription that did not bring
+// its own.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
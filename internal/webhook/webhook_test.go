@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// allowAll lets the tests deliver to httptest receivers on loopback.
+func allowAll(netip.Addr) bool { return true }
+
+func TestSend(t *testing.T) {
+	const secret = "0123456789abcdef"
+	payload := []byte(`{"id":"1","type":"post.created","data":{}}`)
+
+	t.Run("Should sign deliveries so the receiver can verify them", func(t *testing.T) {
+		var verifyErr error
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			verifyErr = Verify(secret, r.Header, body, time.Minute)
+
+			if r.Header.Get(EventHeader) != "post.created" || r.Header.Get(DeliveryHeader) != "7" {
+				t.Errorf("Unexpected delivery headers %v", r.Header)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer receiver.Close()
+
+		res, err := newClient(time.Second, allowAll).Send(context.Background(), Request{
+			URL:        receiver.URL,
+			Secret:     secret,
+			DeliveryID: 7,
+			Event:      "post.created",
+			Payload:    payload,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.OK() {
+			t.Errorf("Expected a successful response, but got %d", res.Status)
+		}
+		if verifyErr != nil {
+			t.Errorf("Expected a valid signature, but got %v", verifyErr)
+		}
+	})
+
+	t.Run("Should report failed responses", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer receiver.Close()
+
+		res, err := newClient(time.Second, allowAll).Send(context.Background(), Request{URL: receiver.URL, Secret: secret, Payload: payload})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.OK() || res.Status != http.StatusServiceUnavailable {
+			t.Errorf("Expected the 503 response to be reported, but got %+v", res)
+		}
+	})
+
+	t.Run("Should refuse to connect to internal addresses", func(t *testing.T) {
+		var called bool
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer receiver.Close()
+
+		_, err := NewClient(time.Second).Send(context.Background(), Request{URL: receiver.URL, Secret: secret, Payload: payload})
+		if !errors.Is(err, ErrAddressForbidden) {
+			t.Errorf("Expected ErrAddressForbidden, but got %v", err)
+		}
+		if called {
+			t.Error("Expected the loopback receiver not to be called")
+		}
+	})
+
+	t.Run("Should reject tampered payloads", func(t *testing.T) {
+		header := http.Header{}
+		now := time.Now()
+		header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		header.Set(SignatureHeader, Sign(secret, now, payload))
+
+		if err := Verify(secret, header, []byte(`{"id":"2"}`), time.Minute); err != ErrInvalidSignature {
+			t.Errorf("Expected ErrInvalidSignature, but got %v", err)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Second * 30,
+		2:  time.Minute,
+		5:  time.Minute * 8,
+		20: time.Hour * 6,
+	}
+
+	for attempts, want := range tests {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Expected a backoff of %s after %d attempts, but got %s", want, attempts, got)
+		}
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"fe80::1":              false,
+		"fd00:ec2::254":        false,
+		"224.0.0.1":            false,
+		"::ffff:127.0.0.1":     false,
+	}
+
+	for addr, want := range tests {
+		if got := PublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := map[string]error{
+		"https://93.184.216.34/hooks":        nil,
+		"ftp://93.184.216.34/hooks":          ErrInvalidURL,
+		"https:///hooks":                     ErrInvalidURL,
+		"http://127.0.0.1:8080/hooks":        ErrAddressForbidden,
+		"http://[::1]/hooks":                 ErrAddressForbidden,
+		"http://169.254.169.254/latest/meta": ErrAddressForbidden,
+	}
+
+	for rawURL, want := range tests {
+		if err := CheckURL(context.Background(), rawURL); !errors.Is(err, want) {
+			t.Errorf("CheckURL(%s) = %v, want %v", rawURL, err, want)
+		}
+	}
+}